@@ -0,0 +1,129 @@
+package api
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/CanalTP/forseti/internal/manager"
+	"github.com/CanalTP/forseti/internal/sources"
+
+	"github.com/gin-gonic/contrib/ginrus"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// AdminResponse defines the object returned by the admin endpoints, with the status of the source after the action
+type AdminResponse struct {
+	Message  string          `json:"message,omitempty"`
+	Duration string          `json:"duration,omitempty"`
+	Status   *sources.Status `json:"status,omitempty"`
+}
+
+// TokenAuth rejects the requests without the header "Authorization: Bearer <token>"
+func TokenAuth(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader("Authorization")
+		given := strings.TrimPrefix(header, "Bearer ")
+		if token == "" || !strings.HasPrefix(header, "Bearer ") ||
+			subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, AdminResponse{Message: "invalid token"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// adminHandler gives the refreshable source of the path (/sources/parkings/... or /sources/parkings:tram/...)
+// to action and returns its status, an error of the action is returned with the http code given by the action
+func adminHandler(manager *manager.DataManager,
+	action func(c *gin.Context, source sources.Refreshable) (int, error)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		response := AdminResponse{}
+		source := manager.GetSource(c.Param("module"))
+		if source == nil {
+			response.Message = fmt.Sprintf("no source %s", c.Param("module"))
+			c.JSON(http.StatusNotFound, response)
+			return
+		}
+		refreshable, ok := source.(sources.Refreshable)
+		if !ok {
+			response.Message = fmt.Sprintf("source %s is not refreshed periodically", c.Param("module"))
+			c.JSON(http.StatusBadRequest, response)
+			return
+		}
+		begin := time.Now()
+		code, err := action(c, refreshable)
+		response.Duration = time.Since(begin).String()
+		if err != nil {
+			response.Message = err.Error()
+			c.JSON(code, response)
+			return
+		}
+		status := source.GetStatus()
+		response.Status = &status
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// PauseHandler deactivates the periodic refresh of a source, the loaded data are still served
+func PauseHandler(manager *manager.DataManager) gin.HandlerFunc {
+	return adminHandler(manager, func(c *gin.Context, source sources.Refreshable) (int, error) {
+		source.ManageRefreshStatus(false)
+		logrus.Infof("refresh of %s paused", c.Param("module"))
+		return http.StatusOK, nil
+	})
+}
+
+// ResumeHandler activates the periodic refresh of a source
+func ResumeHandler(manager *manager.DataManager) gin.HandlerFunc {
+	return adminHandler(manager, func(c *gin.Context, source sources.Refreshable) (int, error) {
+		source.ManageRefreshStatus(true)
+		logrus.Infof("refresh of %s resumed", c.Param("module"))
+		return http.StatusOK, nil
+	})
+}
+
+// RefreshHandler loads now the data of a source, with its reference data, and answers once they are loaded.
+// A failure of the load is returned as a 502.
+func RefreshHandler(manager *manager.DataManager) gin.HandlerFunc {
+	return adminHandler(manager, func(c *gin.Context, source sources.Refreshable) (int, error) {
+		logrus.Infof("refresh of %s forced", c.Param("module"))
+		if err := source.ForceRefresh(); err != nil {
+			return http.StatusBadGateway, err
+		}
+		return http.StatusOK, nil
+	})
+}
+
+// RefreshIntervalHandler changes the refresh period of a source with the parameter interval (ex: 30s, 5m)
+func RefreshIntervalHandler(manager *manager.DataManager) gin.HandlerFunc {
+	return adminHandler(manager, func(c *gin.Context, source sources.Refreshable) (int, error) {
+		value := c.Query("interval")
+		if value == "" {
+			value = c.PostForm("interval")
+		}
+		interval, err := time.ParseDuration(value)
+		if err != nil || interval <= 0 {
+			return http.StatusBadRequest, fmt.Errorf("interval must be a positive duration (ex: 30s, 5m)")
+		}
+		source.SetRefresh(interval)
+		logrus.Infof("refresh interval of %s changed to %s", c.Param("module"), interval)
+		return http.StatusOK, nil
+	})
+}
+
+// SetupAdminRouter creates the router of the admin API, all its endpoints need the token
+func SetupAdminRouter(manager *manager.DataManager, token string) *gin.Engine {
+	r := gin.New()
+	r.Use(ginrus.Ginrus(logrus.StandardLogger(), time.RFC3339, false))
+	r.Use(gin.Recovery())
+	r.Use(TokenAuth(token))
+	r.POST("/sources/:module/pause", PauseHandler(manager))
+	r.POST("/sources/:module/resume", ResumeHandler(manager))
+	r.POST("/sources/:module/refresh", RefreshHandler(manager))
+	r.POST("/sources/:module/refresh-interval", RefreshIntervalHandler(manager))
+	return r
+}
@@ -0,0 +1,96 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/CanalTP/forseti/internal/manager"
+	"github.com/CanalTP/forseti/internal/parkings"
+)
+
+func TestAdminAPI(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	parkingURI, err := url.Parse(fmt.Sprintf("file://%s/parkings.txt", fixtureDir))
+	require.Nil(err)
+
+	parkingsContext := &parkings.ParkingsContext{}
+	source := parkings.NewParkingsSource(parkingsContext, *parkingURI, time.Hour, defaultTimeout)
+	var manager manager.DataManager
+	manager.AddSource(source)
+	admin := SetupAdminRouter(&manager, "secret")
+
+	post := func(path, token string) (int, AdminResponse) {
+		request := httptest.NewRequest("POST", path, nil)
+		if token != "" {
+			request.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		admin.ServeHTTP(w, request)
+		var response AdminResponse
+		require.Nil(json.Unmarshal(w.Body.Bytes(), &response))
+		return w.Code, response
+	}
+
+	// the token is required
+	code, _ := post("/sources/parkings/pause", "")
+	assert.Equal(401, code)
+	code, _ = post("/sources/parkings/pause", "wrong")
+	assert.Equal(401, code)
+	// the token without the scheme Bearer is rejected
+	request := httptest.NewRequest("POST", "/sources/parkings/pause", nil)
+	request.Header.Set("Authorization", "secret")
+	w := httptest.NewRecorder()
+	admin.ServeHTTP(w, request)
+	assert.Equal(401, w.Code)
+	code, _ = post("/sources/unknown/pause", "secret")
+	assert.Equal(404, code)
+
+	code, response := post("/sources/parkings/pause", "secret")
+	require.Equal(200, code)
+	assert.False(response.Status.RefreshActive)
+	code, response = post("/sources/parkings/resume", "secret")
+	require.Equal(200, code)
+	assert.True(response.Status.RefreshActive)
+
+	code, _ = post("/sources/parkings/refresh-interval?interval=never", "secret")
+	assert.Equal(400, code)
+	code, response = post("/sources/parkings/refresh-interval?interval=2m", "secret")
+	require.Equal(200, code)
+	assert.Equal("2m0s", response.Status.RefreshTime)
+
+	// a forced refresh loads the data before answering, even when paused
+	_, _ = post("/sources/parkings/pause", "secret")
+	code, response = post("/sources/parkings/refresh", "secret")
+	require.Equal(200, code)
+	assert.NotZero(parkingsContext.GetParkingsCount())
+	assert.False(response.Status.LastUpdate.IsZero())
+}
+
+func TestAdminRefreshFailure(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	missingURI, err := url.Parse(fmt.Sprintf("file://%s/missing.txt", fixtureDir))
+	require.Nil(err)
+
+	var manager manager.DataManager
+	manager.AddSource(parkings.NewParkingsSource(&parkings.ParkingsContext{}, *missingURI, time.Hour, defaultTimeout))
+	admin := SetupAdminRouter(&manager, "secret")
+
+	request := httptest.NewRequest("POST", "/sources/parkings/refresh", nil)
+	request.Header.Set("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+	admin.ServeHTTP(w, request)
+	require.Equal(502, w.Code)
+	var response AdminResponse
+	require.Nil(json.Unmarshal(w.Body.Bytes(), &response))
+	assert.NotEmpty(response.Message)
+	assert.Nil(response.Status)
+}
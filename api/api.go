@@ -1,17 +1,21 @@
 package api
 
 import (
+	"fmt"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/CanalTP/forseti"
+	"github.com/CanalTP/forseti/internal/breaker"
 	"github.com/CanalTP/forseti/internal/departures"
 	"github.com/CanalTP/forseti/internal/equipments"
 	"github.com/CanalTP/forseti/internal/freefloatings"
 	"github.com/CanalTP/forseti/internal/manager"
 	"github.com/CanalTP/forseti/internal/parkings"
+	"github.com/CanalTP/forseti/internal/sources"
 	"github.com/CanalTP/forseti/internal/vehicleoccupancies"
+	vehicleoccupanciesv2 "github.com/CanalTP/forseti/internal/vehicleoccupancies_v2"
 	"github.com/CanalTP/forseti/internal/vehiclepositions"
 
 	"github.com/gin-contrib/pprof"
@@ -29,16 +33,36 @@ type LoadingStatus struct {
 	LastUpdate    time.Time `json:"last_update"`
 }
 
-// StatusResponse defines the object returned by the /status endpoint
+// StatusResponse defines the object returned by the /status endpoint, the status of each source is given in
+// Sources, the other fields give the status of the default instance of the modules as before the sources
 type StatusResponse struct {
-	Status              string        `json:"status,omitempty"`
-	Version             string        `json:"version,omitempty"`
-	LastDepartureUpdate time.Time     `json:"last_departure_update"`
-	LastParkingUpdate   time.Time     `json:"last_parking_update"`
-	LastEquipmentUpdate time.Time     `json:"last_equipment_update"`
-	FreeFloatings       LoadingStatus `json:"free_floatings,omitempty"`
-	VehicleOccupancies  LoadingStatus `json:"vehicle_occupancies,omitempty"`
-	VehiclePositions    LoadingStatus `json:"vehicle_positions,omitempty"`
+	Status              string                    `json:"status,omitempty"`
+	Version             string                    `json:"version,omitempty"`
+	LastDepartureUpdate time.Time                 `json:"last_departure_update"`
+	LastParkingUpdate   time.Time                 `json:"last_parking_update"`
+	LastEquipmentUpdate time.Time                 `json:"last_equipment_update"`
+	FreeFloatings       LoadingStatus             `json:"free_floatings,omitempty"`
+	VehicleOccupancies  LoadingStatus             `json:"vehicle_occupancies,omitempty"`
+	VehiclePositions    LoadingStatus             `json:"vehicle_positions,omitempty"`
+	Sources             map[string]sources.Status `json:"sources"`
+	Breakers            []breaker.Snapshot        `json:"breakers"`
+}
+
+// newLoadingStatus returns the loading status of a module from the status of its source
+func newLoadingStatus(status sources.Status) LoadingStatus {
+	return LoadingStatus{status.Source, status.RefreshActive, status.RefreshTime, status.LastUpdate}
+}
+
+// ReadyResponse defines the object returned by the /readyz endpoint, with the reason of each source not ready
+type ReadyResponse struct {
+	Status   string            `json:"status"`
+	NotReady map[string]string `json:"not_ready,omitempty"`
+}
+
+// StatusDetailsResponse defines the object returned by the /status/{module} endpoint
+type StatusDetailsResponse struct {
+	Message string           `json:"message,omitempty"`
+	Details *sources.Details `json:"details,omitempty"`
 }
 
 var (
@@ -63,81 +87,79 @@ var (
 
 func StatusHandler(manager *manager.DataManager) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var lastFreeFloatingsDataUpdate time.Time
-		var loadFreeFloatingData bool = false
-		var refreshFreeFloatingData string
-		var freeFloatingsSource string
-		if manager.GetFreeFloatingsContext() != nil {
-			// manage freefloating activation /status?free_floatings=true or false
-			freeFloatingStatus := c.Query("free_floatings")
-			if len(freeFloatingStatus) > 0 {
-				toActive, _ := strconv.ParseBool(freeFloatingStatus)
-				manager.GetFreeFloatingsContext().ManageFreeFloatingsStatus(toActive)
-			}
-			freeFloatingsSource = manager.GetFreeFloatingsContext().GetPackageName()
-			lastFreeFloatingsDataUpdate = manager.GetFreeFloatingsContext().GetLastFreeFloatingsDataUpdate()
-			loadFreeFloatingData = manager.GetFreeFloatingsContext().LoadFreeFloatingsData()
-			refreshFreeFloatingData = manager.GetFreeFloatingsContext().GetRereshTime()
+		statuses := make(map[string]sources.Status)
+		for _, source := range manager.GetSources() {
+			statuses[sources.Key(source)] = source.GetStatus()
 		}
 
-		var lastVehicleOccupanciesDataUpdate time.Time
-		var loadVehicleOccupanciesData bool = false
-		var refreshVehicleOccupanciesData string
-		if manager.GetVehicleOccupanciesContext() != nil {
-			// manage vehicleoccupancy activation /status?vehicle_occupancies=true or false
-			vehicleOccupancyStatus := c.Query("vehicle_occupancies")
-			if len(vehicleOccupancyStatus) > 0 {
-				toActive, _ := strconv.ParseBool(vehicleOccupancyStatus)
-				manager.GetVehicleOccupanciesContext().ManageVehicleOccupancyStatus(toActive)
-			}
-			lastVehicleOccupanciesDataUpdate = manager.GetVehicleOccupanciesContext().GetLastVehicleOccupanciesDataUpdate()
-			loadVehicleOccupanciesData = manager.GetVehicleOccupanciesContext().LoadOccupancyData()
-			refreshVehicleOccupanciesData = manager.GetVehicleOccupanciesContext().GetRereshTime()
-		}
+		c.JSON(http.StatusOK, StatusResponse{
+			Status:              "ok",
+			Version:             forseti.ForsetiVersion,
+			LastDepartureUpdate: statuses[departures.ModuleName].LastUpdate,
+			LastParkingUpdate:   statuses[parkings.ModuleName].LastUpdate,
+			LastEquipmentUpdate: statuses[equipments.ModuleName].LastUpdate,
+			FreeFloatings:       newLoadingStatus(statuses[freefloatings.ModuleName]),
+			VehicleOccupancies:  newLoadingStatus(statuses[vehicleoccupanciesv2.ModuleName]),
+			VehiclePositions:    newLoadingStatus(statuses[vehiclepositions.ModuleName]),
+			Sources:             statuses,
+			Breakers:            breaker.Snapshots(),
+		})
+	}
+}
 
-		var lastVehiclePositionsDataUpdate time.Time
-		var loadVehiclePositionsData bool = false
-		var refreshVehiclePositionsData string
-		if manager.GetVehiclePositionsContext() != nil {
-			// manage vehiclepositions activation /status?vehicle_positions=true or false
-			vehiclePositionsStatus := c.Query("vehicle_positions")
-			if len(vehiclePositionsStatus) > 0 {
-				toActive, _ := strconv.ParseBool(vehiclePositionsStatus)
-				manager.GetVehiclePositionsContext().ManageVehiclePositionsStatus(toActive)
-			}
-			lastVehiclePositionsDataUpdate = manager.GetVehiclePositionsContext().GetLastVehiclePositionsDataUpdate()
-			loadVehiclePositionsData = manager.GetVehiclePositionsContext().LoadPositionsData()
-			refreshVehiclePositionsData = manager.GetVehiclePositionsContext().GetRereshTime()
+// StatusDetailsHandler returns the detailed status of a source, /status/parkings or /status/parkings:tram
+// for a named instance
+func StatusDetailsHandler(manager *manager.DataManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		response := StatusDetailsResponse{}
+		source := manager.GetSource(c.Param("module"))
+		if source == nil {
+			response.Message = fmt.Sprintf("no source %s", c.Param("module"))
+			c.JSON(http.StatusNotFound, response)
+			return
 		}
-
-		var lastEquipmentDataUpdate time.Time
-		if manager.GetEquipmentsContext() != nil {
-			lastEquipmentDataUpdate = manager.GetEquipmentsContext().GetLastEquipmentsDataUpdate()
+		details := sources.Details{Status: source.GetStatus()}
+		if detailed, ok := source.(sources.Detailed); ok {
+			details = detailed.GetDetails()
 		}
+		response.Details = &details
+		c.JSON(http.StatusOK, response)
+	}
+}
 
-		var lastDeparturesDataUpdate time.Time
-		if manager.GetDeparturesContext() != nil {
-			lastDeparturesDataUpdate = manager.GetDeparturesContext().GetLastDepartureDataUpdate()
-		}
+// HealthHandler answers as long as the process is alive
+func HealthHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, ReadyResponse{Status: "ok"})
+	}
+}
 
-		var lastParkingsDataUpdate time.Time
-		if manager.GetParkingsContext() != nil {
-			lastParkingsDataUpdate = manager.GetParkingsContext().GetLastParkingsDataUpdate()
+// ReadyHandler answers 200 when every source with an active refresh has loaded its data and has updated them
+// during the last staleness refresh periods, 503 otherwise. The sources serving the data of their snapshot are
+// ready only with acceptStale.
+func ReadyHandler(manager *manager.DataManager, staleness float64, acceptStale bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		notReady := make(map[string]string)
+		now := time.Now()
+		for _, source := range manager.GetSources() {
+			if err := source.GetStatus().Ready(staleness, acceptStale, now); err != nil {
+				notReady[sources.Key(source)] = err.Error()
+			}
 		}
-
-		c.JSON(http.StatusOK, StatusResponse{
-			"ok",
-			forseti.ForsetiVersion,
-			lastDeparturesDataUpdate,
-			lastParkingsDataUpdate,
-			lastEquipmentDataUpdate,
-			LoadingStatus{freeFloatingsSource, loadFreeFloatingData, refreshFreeFloatingData, lastFreeFloatingsDataUpdate},
-			LoadingStatus{"", loadVehicleOccupanciesData, refreshVehicleOccupanciesData, lastVehicleOccupanciesDataUpdate},
-			LoadingStatus{"", loadVehiclePositionsData, refreshVehiclePositionsData, lastVehiclePositionsDataUpdate},
-		})
+		if len(notReady) > 0 {
+			c.JSON(http.StatusServiceUnavailable, ReadyResponse{Status: "not ready", NotReady: notReady})
+			return
+		}
+		c.JSON(http.StatusOK, ReadyResponse{Status: "ok"})
 	}
 }
 
+// AddProbesEntryPoints declares the liveness (/healthz) and readiness (/readyz) probes
+func AddProbesEntryPoints(r *gin.Engine, manager *manager.DataManager, staleness float64, acceptStale bool) {
+	r.GET("/healthz", HealthHandler())
+	r.GET("/readyz", ReadyHandler(manager, staleness, acceptStale))
+}
+
 func SetupRouter(manager *manager.DataManager, r *gin.Engine) *gin.Engine {
 	if r == nil {
 		r = gin.New()
@@ -148,6 +170,7 @@ func SetupRouter(manager *manager.DataManager, r *gin.Engine) *gin.Engine {
 	pprof.Register(r)
 	r.GET("/metrics", gin.WrapH(promhttp.Handler()))
 	r.GET("/status", StatusHandler(manager))
+	r.GET("/status/:module", StatusDetailsHandler(manager))
 
 	return r
 }
@@ -168,14 +191,25 @@ func init() {
 	prometheus.MustRegister(httpInFlight)
 	prometheus.MustRegister(departures.DepartureLoadingDuration)
 	prometheus.MustRegister(departures.DepartureLoadingErrors)
+	prometheus.MustRegister(departures.DepartureSkippedReloads)
+	prometheus.MustRegister(departures.DepartureSkippedRecords)
 	prometheus.MustRegister(parkings.ParkingsLoadingDuration)
 	prometheus.MustRegister(parkings.ParkingsLoadingErrors)
+	prometheus.MustRegister(parkings.ParkingsSkippedReloads)
+	prometheus.MustRegister(parkings.ParkingsSkippedRecords)
 	prometheus.MustRegister(equipments.EquipmentsLoadingDuration)
 	prometheus.MustRegister(equipments.EquipmentsLoadingErrors)
+	prometheus.MustRegister(equipments.EquipmentsSkippedReloads)
 	prometheus.MustRegister(freefloatings.FreeFloatingsLoadingDuration)
 	prometheus.MustRegister(freefloatings.FreeFloatingsLoadingErrors)
 	prometheus.MustRegister(vehicleoccupancies.VehicleOccupanciesLoadingDuration)
 	prometheus.MustRegister(vehicleoccupancies.VehicleOccupanciesLoadingErrors)
 	prometheus.MustRegister(vehiclepositions.VehiclePositionsLoadingDuration)
 	prometheus.MustRegister(vehiclepositions.VehiclePositionsLoadingErrors)
+	prometheus.MustRegister(sources.RefreshDuration)
+	prometheus.MustRegister(sources.RefreshErrors)
+	prometheus.MustRegister(sources.IngestDuration)
+	prometheus.MustRegister(sources.IngestErrors)
+	prometheus.MustRegister(breaker.StateGauge)
+	prometheus.MustRegister(breaker.Rejections)
 }
@@ -60,7 +60,7 @@ func TestStatusApiHasLastUpdateTime(t *testing.T) {
 
 	departuresContext := &departures.DeparturesContext{}
 	var manager manager.DataManager
-	manager.SetDeparturesContext(departuresContext)
+	manager.AddSource(departures.NewDeparturesSource(departuresContext, *firstURI, time.Second, defaultTimeout))
 
 	c, router := gin.CreateTestContext(httptest.NewRecorder())
 	departures.AddDeparturesEntryPoint(router, departuresContext)
@@ -78,8 +78,11 @@ func TestStatusApiHasLastUpdateTime(t *testing.T) {
 	err = json.Unmarshal(w.Body.Bytes(), &response)
 	require.Nil(err)
 	assert.Equal(response.Status, "ok")
-	assert.True(response.LastDepartureUpdate.After(startTime))
-	assert.True(response.LastDepartureUpdate.Before(time.Now()))
+	assert.True(response.Sources["departures"].LastUpdate.After(startTime))
+	assert.True(response.Sources["departures"].LastUpdate.Before(time.Now()))
+	// the fields of the modules are kept for the former clients
+	assert.True(response.LastDepartureUpdate.Equal(response.Sources["departures"].LastUpdate))
+	assert.True(response.LastParkingUpdate.IsZero())
 }
 
 func TestStatusApiHasLastParkingUpdateTime(t *testing.T) {
@@ -91,7 +94,7 @@ func TestStatusApiHasLastParkingUpdateTime(t *testing.T) {
 
 	parkingsContext := &parkings.ParkingsContext{}
 	var manager manager.DataManager
-	manager.SetParkingsContext(parkingsContext)
+	manager.AddSource(parkings.NewParkingsSource(parkingsContext, *parkingURI, time.Second, defaultTimeout))
 
 	c, router := gin.CreateTestContext(httptest.NewRecorder())
 	parkings.AddParkingsEntryPoint(router, parkingsContext)
@@ -108,8 +111,87 @@ func TestStatusApiHasLastParkingUpdateTime(t *testing.T) {
 	var response StatusResponse
 	err = json.Unmarshal(w.Body.Bytes(), &response)
 	require.Nil(err)
-	assert.True(response.LastParkingUpdate.After(startTime))
-	assert.True(response.LastParkingUpdate.Before(time.Now()))
+	assert.True(response.Sources["parkings"].LastUpdate.After(startTime))
+	assert.True(response.Sources["parkings"].LastUpdate.Before(time.Now()))
+	assert.True(response.LastParkingUpdate.Equal(response.Sources["parkings"].LastUpdate))
+}
+
+func TestStatusDetails(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	parkingURI, err := url.Parse(fmt.Sprintf("file://%s/parkings.txt", fixtureDir))
+	require.Nil(err)
+
+	parkingsContext := &parkings.ParkingsContext{}
+	source := parkings.NewParkingsSource(parkingsContext, *parkingURI, time.Second, defaultTimeout)
+	var manager manager.DataManager
+	manager.AddSource(source)
+	router := SetupRouter(&manager, nil)
+
+	require.Nil(source.Refresh())
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/status/parkings", nil))
+	require.Equal(200, w.Code)
+
+	var response StatusDetailsResponse
+	err = json.Unmarshal(w.Body.Bytes(), &response)
+	require.Nil(err)
+	require.NotNil(response.Details)
+	assert.NotZero(response.Details.LoadedItems)
+	assert.Equal(parkingsContext.GetParkingsCount(), response.Details.LoadedItems)
+	assert.Equal(response.Details.LastSuccess, response.Details.LastAttempt)
+	assert.Equal(0, response.Details.ConsecutiveFailures)
+	assert.Equal(parkingURI.String(), response.Details.URI)
+	assert.Len(response.Details.History, 1)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/status/parkings:tram", nil))
+	assert.Equal(404, w.Code)
+}
+
+func TestProbes(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	parkingURI, err := url.Parse(fmt.Sprintf("file://%s/parkings.txt", fixtureDir))
+	require.Nil(err)
+
+	source := parkings.NewParkingsSource(&parkings.ParkingsContext{}, *parkingURI, time.Second, defaultTimeout)
+	var manager manager.DataManager
+	manager.AddSource(source)
+	router := SetupRouter(&manager, nil)
+	AddProbesEntryPoints(router, &manager, 3, false)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/healthz", nil))
+	assert.Equal(200, w.Code)
+
+	// no data loaded yet
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/readyz", nil))
+	require.Equal(503, w.Code)
+	var response ReadyResponse
+	require.Nil(json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal("no data loaded", response.NotReady["parkings"])
+
+	require.Nil(source.Refresh())
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/readyz", nil))
+	assert.Equal(200, w.Code)
+
+	// a source whose refresh is inactive is not ready until its data are loaded
+	inactive := parkings.NewParkingsSource(&parkings.ParkingsContext{}, *parkingURI, time.Second, defaultTimeout)
+	inactive.ManageRefreshStatus(false)
+	manager.AddSource(inactive)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/readyz", nil))
+	require.Equal(503, w.Code)
+	require.Nil(json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Len(response.NotReady, 1)
+
+	require.Nil(inactive.ForceRefresh())
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/readyz", nil))
+	assert.Equal(200, w.Code)
 }
 
 func TestParameterTypes(t *testing.T) {
@@ -206,7 +288,8 @@ func TestVehicleOccupanciesAPIWithDataFromFile(t *testing.T) {
 	engine = SetupRouter(&manager, engine)
 
 	// Verify /status
-	manager.SetVehicleOccupanciesContext(vehicleOccupanciesOditiContext)
+	manager.AddSource(vehicleoccupanciesv2.NewVehicleOccupanciesSource(vehicleOccupanciesOditiContext, url.URL{}, "",
+		url.URL{}, "", 0, 0, 0, defaultTimeout, loc))
 	c.Request = httptest.NewRequest("GET", "/status", nil)
 	w := httptest.NewRecorder()
 	engine.ServeHTTP(w, c.Request)
@@ -216,21 +299,37 @@ func TestVehicleOccupanciesAPIWithDataFromFile(t *testing.T) {
 	err = json.Unmarshal(w.Body.Bytes(), &status)
 
 	require.Nil(err)
-	assert.True(status.VehicleOccupancies.LastUpdate.After(startTime))
-	assert.True(status.VehicleOccupancies.LastUpdate.Before(time.Now()))
-	assert.False(status.VehicleOccupancies.RefreshActive)
-	assert.False(status.FreeFloatings.RefreshActive)
+	assert.True(status.Sources["vehicle_occupancies"].LastUpdate.After(startTime))
+	assert.True(status.Sources["vehicle_occupancies"].LastUpdate.Before(time.Now()))
+	assert.False(status.Sources["vehicle_occupancies"].RefreshActive)
+	assert.False(status.Sources["free_floatings"].RefreshActive)
 
-	// Activate the periodic refresh of data
+	// The periodic refresh of data can not be activated from /status
 	c.Request = httptest.NewRequest("GET", "/status?vehicle_occupancies=true", nil)
 	w = httptest.NewRecorder()
 	engine.ServeHTTP(w, c.Request)
 	require.Equal(200, w.Code)
 	err = json.Unmarshal(w.Body.Bytes(), &status)
+	require.Nil(err)
+	assert.False(status.Sources["vehicle_occupancies"].RefreshActive)
+
+	// Activate the periodic refresh of data with the admin API
+	admin := SetupAdminRouter(&manager, "secret")
+	c.Request = httptest.NewRequest("POST", "/sources/vehicle_occupancies/resume", nil)
+	c.Request.Header.Set("Authorization", "Bearer secret")
+	w = httptest.NewRecorder()
+	admin.ServeHTTP(w, c.Request)
+	require.Equal(200, w.Code)
+
+	c.Request = httptest.NewRequest("GET", "/status", nil)
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, c.Request)
+	require.Equal(200, w.Code)
+	err = json.Unmarshal(w.Body.Bytes(), &status)
 
 	require.Nil(err)
-	assert.True(status.VehicleOccupancies.RefreshActive)
-	assert.False(status.FreeFloatings.RefreshActive)
+	assert.True(status.Sources["vehicle_occupancies"].RefreshActive)
+	assert.False(status.Sources["free_floatings"].RefreshActive)
 }
 
 func TestStatusInFreeFloatingWithDataFromFile(t *testing.T) {
@@ -261,7 +360,7 @@ func TestStatusInFreeFloatingWithDataFromFile(t *testing.T) {
 	freefloatings.AddFreeFloatingsEntryPoint(router, freeFloatingsContext)
 
 	manager := &manager.DataManager{}
-	manager.SetFreeFloatingsContext(freeFloatingsContext)
+	manager.AddSource(freefloatings.NewFreeFloatingsSource(freeFloatingsContext, nil))
 	router.GET("/status", StatusHandler(manager))
 	c.Request = httptest.NewRequest("GET", "/status", nil)
 	w := httptest.NewRecorder()
@@ -272,19 +371,29 @@ func TestStatusInFreeFloatingWithDataFromFile(t *testing.T) {
 	err = json.Unmarshal(w.Body.Bytes(), &response)
 	require.Nil(err)
 
-	assert.True(response.FreeFloatings.LastUpdate.After(startTime))
-	assert.True(response.FreeFloatings.LastUpdate.Before(time.Now()))
-	assert.False(response.FreeFloatings.RefreshActive)
-	assert.False(response.VehicleOccupancies.RefreshActive)
+	assert.True(response.Sources["free_floatings"].LastUpdate.After(startTime))
+	assert.True(response.Sources["free_floatings"].LastUpdate.Before(time.Now()))
+	assert.False(response.Sources["free_floatings"].RefreshActive)
+	assert.False(response.Sources["vehicle_occupancies"].RefreshActive)
+
+	// Activate the periodic refresh of data with the admin API
+	admin := SetupAdminRouter(manager, "secret")
+	c.Request = httptest.NewRequest("POST", "/sources/free_floatings/resume", nil)
+	c.Request.Header.Set("Authorization", "Bearer secret")
+	w = httptest.NewRecorder()
+	admin.ServeHTTP(w, c.Request)
+	require.Equal(200, w.Code)
 
-	// Activate the periodic refresh of data
-	c.Request = httptest.NewRequest("GET", "/status?free_floatings=true", nil)
+	c.Request = httptest.NewRequest("GET", "/status", nil)
 	w = httptest.NewRecorder()
 	router.ServeHTTP(w, c.Request)
 	require.Equal(200, w.Code)
 	err = json.Unmarshal(w.Body.Bytes(), &response)
 
 	require.Nil(err)
+	assert.True(response.Sources["free_floatings"].RefreshActive)
+	assert.False(response.Sources["vehicle_occupancies"].RefreshActive)
 	assert.True(response.FreeFloatings.RefreshActive)
+	assert.True(response.FreeFloatings.LastUpdate.Equal(response.Sources["free_floatings"].LastUpdate))
 	assert.False(response.VehicleOccupancies.RefreshActive)
 }
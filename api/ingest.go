@@ -0,0 +1,77 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/CanalTP/forseti/internal/manager"
+	"github.com/CanalTP/forseti/internal/sources"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// errPayloadTooLarge is returned by the reads of a payload beyond its maximum size
+var errPayloadTooLarge = errors.New("payload too large")
+
+// limitedReader reads at most remaining bytes, the next reads fail with errPayloadTooLarge
+type limitedReader struct {
+	reader    io.Reader
+	remaining int64
+	exceeded  bool
+}
+
+func (r *limitedReader) Read(p []byte) (int, error) {
+	if r.remaining <= 0 {
+		// the payload is too large only if there is something left to read
+		var b [1]byte
+		if n, _ := r.reader.Read(b[:]); n > 0 {
+			r.exceeded = true
+			return 0, errPayloadTooLarge
+		}
+		return 0, io.EOF
+	}
+	if int64(len(p)) > r.remaining {
+		p = p[:r.remaining]
+	}
+	n, err := r.reader.Read(p)
+	r.remaining -= int64(n)
+	return n, err
+}
+
+// IngestHandler loads the data pushed in the body of the request into the source of the path (/ingest/parkings
+// or /ingest/parkings:tram), in the format of the data loaded by the source. A payload larger than maxSize
+// bytes is rejected with a 413, an invalid payload with a 400, and the served data are then kept.
+func IngestHandler(manager *manager.DataManager, maxSize int64) gin.HandlerFunc {
+	return adminHandler(manager, func(c *gin.Context, source sources.Refreshable) (int, error) {
+		ingester, ok := source.(sources.Ingester)
+		if !ok {
+			return http.StatusBadRequest, fmt.Errorf("source %s does not accept pushed data", c.Param("module"))
+		}
+		if c.Request.ContentLength > maxSize {
+			return http.StatusRequestEntityTooLarge, fmt.Errorf("the payload exceeds %d bytes", maxSize)
+		}
+		body := &limitedReader{reader: c.Request.Body, remaining: maxSize}
+		err := ingester.Ingest(body)
+		switch {
+		case body.exceeded:
+			return http.StatusRequestEntityTooLarge, fmt.Errorf("the payload exceeds %d bytes", maxSize)
+		case err == sources.ErrNoIngest:
+			return http.StatusBadRequest, fmt.Errorf("source %s does not accept pushed data", c.Param("module"))
+		case err != nil:
+			return http.StatusBadRequest, fmt.Errorf("invalid payload: %s", err)
+		}
+		logrus.Infof("data of %s pushed", c.Param("module"))
+		return http.StatusOK, nil
+	})
+}
+
+// AddIngestEntryPoints declares the endpoints receiving the data pushed by the providers of the sources,
+// PUT or POST /ingest/{module}, they need the token
+func AddIngestEntryPoints(r *gin.Engine, manager *manager.DataManager, token string, maxSize int64) {
+	ingest := r.Group("/ingest", TokenAuth(token))
+	ingest.POST("/:module", IngestHandler(manager, maxSize))
+	ingest.PUT("/:module", IngestHandler(manager, maxSize))
+}
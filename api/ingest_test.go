@@ -0,0 +1,84 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/CanalTP/forseti/internal/departures"
+	"github.com/CanalTP/forseti/internal/manager"
+	"github.com/CanalTP/forseti/internal/parkings"
+)
+
+func TestIngestAPI(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	uri, err := url.Parse(fmt.Sprintf("file://%s/missing.txt", fixtureDir))
+	require.Nil(err)
+
+	departuresContext := &departures.DeparturesContext{}
+	parkingsContext := &parkings.ParkingsContext{}
+	var manager manager.DataManager
+	manager.AddSource(departures.NewDeparturesSource(departuresContext, *uri, time.Hour, defaultTimeout))
+	manager.AddSource(parkings.NewParkingsSource(parkingsContext, *uri, time.Hour, defaultTimeout))
+	router := SetupRouter(&manager, nil)
+	AddIngestEntryPoints(router, &manager, "secret", 1<<20)
+
+	push := func(method, path, token string, payload io.Reader) (int, AdminResponse) {
+		request := httptest.NewRequest(method, path, payload)
+		if token != "" {
+			request.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, request)
+		var response AdminResponse
+		require.Nil(json.Unmarshal(w.Body.Bytes(), &response))
+		return w.Code, response
+	}
+	fixture := func(name string) io.Reader {
+		file, err := os.Open(fmt.Sprintf("%s/%s", fixtureDir, name))
+		require.Nil(err)
+		t.Cleanup(func() { file.Close() })
+		return file
+	}
+
+	// the token is required
+	code, _ := push("POST", "/ingest/departures", "", fixture("first.txt"))
+	assert.Equal(401, code)
+	code, _ = push("POST", "/ingest/unknown", "secret", fixture("first.txt"))
+	assert.Equal(404, code)
+
+	code, response := push("POST", "/ingest/departures", "secret", fixture("first.txt"))
+	require.Equal(200, code, response.Message)
+	assert.False(response.Status.LastUpdate.IsZero())
+	count := departuresContext.GetDeparturesCount()
+	assert.NotZero(count)
+
+	code, response = push("PUT", "/ingest/parkings", "secret", fixture("parkings.txt"))
+	require.Equal(200, code, response.Message)
+	assert.NotZero(parkingsContext.GetParkingsCount())
+
+	// an invalid payload is rejected with its error, the departures are kept
+	code, response = push("PUT", "/ingest/departures", "secret", fixture("invaliddate.txt"))
+	assert.Equal(400, code)
+	assert.Contains(response.Message, "invalid payload")
+	assert.Equal(count, departuresContext.GetDeparturesCount())
+
+	// the size of the payload is limited, even when its length is unknown
+	large := strings.Repeat("x", 1<<20+1)
+	code, _ = push("PUT", "/ingest/departures", "secret", strings.NewReader(large))
+	assert.Equal(413, code)
+	code, response = push("PUT", "/ingest/departures", "secret", io.MultiReader(strings.NewReader(large)))
+	assert.Equal(413, code)
+	assert.Equal("the payload exceeds 1048576 bytes", response.Message)
+	assert.Equal(count, departuresContext.GetDeparturesCount())
+}
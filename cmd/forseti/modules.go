@@ -0,0 +1,14 @@
+package main
+
+// Data modules available in forseti, importing a package registers its module.
+// A new module or connector only needs to be added here.
+
+import (
+	_ "github.com/CanalTP/forseti/internal/departures"
+	_ "github.com/CanalTP/forseti/internal/equipments"
+	_ "github.com/CanalTP/forseti/internal/freefloatings/citiz"
+	_ "github.com/CanalTP/forseti/internal/freefloatings/fluctuo"
+	_ "github.com/CanalTP/forseti/internal/parkings"
+	_ "github.com/CanalTP/forseti/internal/vehicleoccupancies_v2"
+	_ "github.com/CanalTP/forseti/internal/vehiclepositions"
+)
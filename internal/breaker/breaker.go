@@ -0,0 +1,277 @@
+package breaker
+
+// This module protects the upstream providers (Fluctuo, Citiz, Oditi, Navitia, GTFS-RT feeds) from being called
+// on every refresh while they are failing. Each upstream has its own circuit breaker, identified by a name,
+// which opens after a number of consecutive failures: the calls are then rejected without reaching the upstream.
+// After a cool-down the breaker is half-open, a single call is let through: its success closes the breaker,
+// its failure opens it again for a new cool-down.
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// State of a circuit breaker
+type State int
+
+const (
+	Closed State = iota
+	Open
+	HalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// Default configuration of the breakers
+const (
+	DefaultThreshold = 5
+	DefaultCooldown  = time.Minute
+)
+
+// ErrOpen is returned, wrapped with the name of the upstream, by the calls rejected by an open breaker
+var ErrOpen = errors.New("circuit breaker open")
+
+var (
+	StateGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "forseti",
+		Subsystem: "breaker",
+		Name:      "state",
+		Help:      "state of the circuit breaker of an upstream: 0 closed, 1 open, 2 half-open",
+	},
+		[]string{"upstream"},
+	)
+
+	Rejections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "forseti",
+		Subsystem: "breaker",
+		Name:      "rejected_calls",
+		Help:      "number of calls to an upstream rejected by its open circuit breaker",
+	},
+		[]string{"upstream"},
+	)
+)
+
+// Snapshot is the state of a breaker exposed by the status
+type Snapshot struct {
+	Upstream            string     `json:"upstream"`
+	State               string     `json:"state"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	OpenedAt            *time.Time `json:"opened_at,omitempty"`
+}
+
+// Breaker is the circuit breaker of an upstream
+type Breaker struct {
+	name      string
+	threshold int
+	cooldown  time.Duration
+	state     State
+	failures  int
+	openedAt  time.Time
+	trial     bool
+	now       func() time.Time
+	mutex     sync.Mutex
+}
+
+// NewBreaker creates a closed breaker opened after threshold consecutive failures (0 to disable the breaker)
+// and half-opened after the cool-down
+func NewBreaker(name string, threshold int, cooldown time.Duration) *Breaker {
+	b := &Breaker{name: name, threshold: threshold, cooldown: cooldown, now: time.Now}
+	b.publish()
+	return b
+}
+
+// Name returns the name of the upstream of the breaker
+func (b *Breaker) Name() string {
+	return b.name
+}
+
+// Configure changes the threshold and the cool-down of the breaker, its state is kept
+func (b *Breaker) Configure(threshold int, cooldown time.Duration) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.threshold, b.cooldown = threshold, cooldown
+}
+
+// Call calls fn if the breaker allows it, an error returned by fn is a failure of the upstream.
+// A rejected call returns an error wrapping ErrOpen.
+func (b *Breaker) Call(fn func() error) error {
+	if err := b.allow(); err != nil {
+		Rejections.With(prometheus.Labels{"upstream": b.name}).Inc()
+		return err
+	}
+	err := fn()
+	b.done(err)
+	return err
+}
+
+// State returns the current state of the breaker, an open breaker whose cool-down is over is half-open
+func (b *Breaker) State() State {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.expire()
+	return b.state
+}
+
+// Snapshot returns the state of the breaker
+func (b *Breaker) Snapshot() Snapshot {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.expire()
+	snapshot := Snapshot{Upstream: b.name, State: b.state.String(), ConsecutiveFailures: b.failures}
+	if b.state != Closed {
+		openedAt := b.openedAt
+		snapshot.OpenedAt = &openedAt
+	}
+	return snapshot
+}
+
+// allow returns an error if the call must be rejected, only one call at a time is let through a half-open breaker
+func (b *Breaker) allow() error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.expire()
+	switch {
+	case b.state == Open:
+		return fmt.Errorf("%s: %w until %s", b.name, ErrOpen, b.openedAt.Add(b.cooldown).Format(time.RFC3339))
+	case b.state == HalfOpen && b.trial:
+		return fmt.Errorf("%s: %w, a trial call is in progress", b.name, ErrOpen)
+	case b.state == HalfOpen:
+		b.trial = true
+	}
+	return nil
+}
+
+// done records the result of a call let through the breaker
+func (b *Breaker) done(err error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	previous := b.state
+	b.trial = false
+	if err == nil {
+		b.failures = 0
+		b.state = Closed
+	} else {
+		b.failures++
+		if previous == HalfOpen || (b.threshold > 0 && b.failures >= b.threshold) {
+			b.state = Open
+			b.openedAt = b.now()
+		}
+	}
+	if b.state != previous {
+		b.log(previous)
+	}
+	b.publish()
+}
+
+// expire half-opens the breaker at the end of the cool-down, the mutex must be held
+func (b *Breaker) expire() {
+	if b.state == Open && !b.now().Before(b.openedAt.Add(b.cooldown)) {
+		b.state = HalfOpen
+		b.log(Open)
+		b.publish()
+	}
+}
+
+func (b *Breaker) log(previous State) {
+	switch b.state {
+	case Open:
+		logrus.Warnf("circuit breaker of %s opened after %d consecutive failures, calls rejected for %s",
+			b.name, b.failures, b.cooldown)
+	default:
+		logrus.Infof("circuit breaker of %s %s (was %s)", b.name, b.state, previous)
+	}
+}
+
+func (b *Breaker) publish() {
+	StateGauge.With(prometheus.Labels{"upstream": b.name}).Set(float64(b.state))
+}
+
+// Registry of the breakers of the upstreams, created on their first call with the configuration of the registry
+type Registry struct {
+	threshold int
+	cooldown  time.Duration
+	breakers  map[string]*Breaker
+	mutex     sync.Mutex
+}
+
+// NewRegistry creates an empty registry whose breakers use the given configuration
+func NewRegistry(threshold int, cooldown time.Duration) *Registry {
+	return &Registry{threshold: threshold, cooldown: cooldown, breakers: make(map[string]*Breaker)}
+}
+
+// Get returns the breaker of the upstream, it is created if needed
+func (r *Registry) Get(upstream string) *Breaker {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	b, ok := r.breakers[upstream]
+	if !ok {
+		b = NewBreaker(upstream, r.threshold, r.cooldown)
+		r.breakers[upstream] = b
+	}
+	return b
+}
+
+// Configure changes the configuration of the registry and of its breakers
+func (r *Registry) Configure(threshold int, cooldown time.Duration) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.threshold, r.cooldown = threshold, cooldown
+	for _, b := range r.breakers {
+		b.Configure(threshold, cooldown)
+	}
+}
+
+// Snapshots returns the state of every breaker of the registry, sorted by upstream
+func (r *Registry) Snapshots() []Snapshot {
+	r.mutex.Lock()
+	breakers := make([]*Breaker, 0, len(r.breakers))
+	for _, b := range r.breakers {
+		breakers = append(breakers, b)
+	}
+	r.mutex.Unlock()
+
+	snapshots := make([]Snapshot, 0, len(breakers))
+	for _, b := range breakers {
+		snapshots = append(snapshots, b.Snapshot())
+	}
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].Upstream < snapshots[j].Upstream })
+	return snapshots
+}
+
+// upstreams is the registry used by the connectors
+var upstreams = NewRegistry(DefaultThreshold, DefaultCooldown)
+
+// Get returns the breaker of the upstream from the registry of the connectors
+func Get(upstream string) *Breaker {
+	return upstreams.Get(upstream)
+}
+
+// Call calls fn through the breaker of the upstream
+func Call(upstream string, fn func() error) error {
+	return upstreams.Get(upstream).Call(fn)
+}
+
+// Configure changes the configuration of the breakers of the connectors
+func Configure(threshold int, cooldown time.Duration) {
+	upstreams.Configure(threshold, cooldown)
+}
+
+// Snapshots returns the state of the breakers of the connectors
+func Snapshots() []Snapshot {
+	return upstreams.Snapshots()
+}
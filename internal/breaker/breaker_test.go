@@ -0,0 +1,118 @@
+package breaker
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func newTestBreaker(threshold int, cooldown time.Duration) (*Breaker, *clock) {
+	c := &clock{now: time.Date(2021, 6, 1, 10, 0, 0, 0, time.UTC)}
+	b := NewBreaker("test", threshold, cooldown)
+	b.now = c.Now
+	return b, c
+}
+
+func TestBreakerOpensAfterThreshold(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	b, c := newTestBreaker(3, time.Minute)
+	unreachable := fmt.Errorf("unreachable")
+	calls := 0
+	failing := func() error { calls++; return unreachable }
+
+	for i := 0; i < 2; i++ {
+		assert.Equal(unreachable, b.Call(failing))
+	}
+	assert.Equal(Closed, b.State())
+	assert.Equal(unreachable, b.Call(failing))
+	assert.Equal(Open, b.State())
+	assert.Equal(3, calls)
+
+	// the calls are rejected without calling the upstream during the cool-down
+	err := b.Call(failing)
+	require.Error(err)
+	assert.True(errors.Is(err, ErrOpen))
+	assert.Equal(3, calls)
+
+	snapshot := b.Snapshot()
+	assert.Equal("open", snapshot.State)
+	assert.Equal(3, snapshot.ConsecutiveFailures)
+	require.NotNil(snapshot.OpenedAt)
+	assert.Equal(c.now, *snapshot.OpenedAt)
+}
+
+func TestBreakerHalfOpen(t *testing.T) {
+	assert := assert.New(t)
+
+	b, c := newTestBreaker(1, time.Minute)
+	assert.Error(b.Call(func() error { return fmt.Errorf("unreachable") }))
+	assert.Equal(Open, b.State())
+
+	c.now = c.now.Add(time.Minute)
+	assert.Equal(HalfOpen, b.State())
+
+	// a failure of the trial call opens the breaker for a new cool-down
+	assert.Error(b.Call(func() error { return fmt.Errorf("still unreachable") }))
+	assert.Equal(Open, b.State())
+	assert.True(errors.Is(b.Call(func() error { return nil }), ErrOpen))
+
+	// a single trial call is let through a half-open breaker, its success closes the breaker
+	c.now = c.now.Add(time.Minute)
+	err := b.Call(func() error {
+		assert.True(errors.Is(b.Call(func() error { return nil }), ErrOpen))
+		return nil
+	})
+	assert.Nil(err)
+	assert.Equal(Closed, b.State())
+	assert.Equal(Snapshot{Upstream: "test", State: "closed"}, b.Snapshot())
+}
+
+func TestBreakerSuccessResetsFailures(t *testing.T) {
+	assert := assert.New(t)
+
+	b, _ := newTestBreaker(2, time.Minute)
+	assert.Error(b.Call(func() error { return fmt.Errorf("unreachable") }))
+	assert.Nil(b.Call(func() error { return nil }))
+	assert.Error(b.Call(func() error { return fmt.Errorf("unreachable") }))
+	assert.Equal(Closed, b.State())
+
+	// a threshold of 0 disables the breaker
+	b.Configure(0, time.Minute)
+	for i := 0; i < 10; i++ {
+		assert.Error(b.Call(func() error { return fmt.Errorf("unreachable") }))
+	}
+	assert.Equal(Closed, b.State())
+}
+
+func TestRegistry(t *testing.T) {
+	assert := assert.New(t)
+
+	registry := NewRegistry(1, time.Hour)
+	assert.Equal(registry.Get("navitia"), registry.Get("navitia"))
+	assert.Error(registry.Get("oditi").Call(func() error { return fmt.Errorf("unreachable") }))
+
+	snapshots := registry.Snapshots()
+	assert.Len(snapshots, 2)
+	assert.Equal("navitia", snapshots[0].Upstream)
+	assert.Equal("closed", snapshots[0].State)
+	assert.Equal("oditi", snapshots[1].Upstream)
+	assert.Equal("open", snapshots[1].State)
+
+	registry.Configure(5, time.Minute)
+	assert.Error(registry.Get("citiz:1").Call(func() error { return fmt.Errorf("unreachable") }))
+	assert.Equal(Closed, registry.Get("citiz:1").State())
+}
@@ -37,6 +37,12 @@ func (d *Connector) GetUrl() url.URL {
 	return d.url
 }
 
+func (d *Connector) SetUrl(url url.URL) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.url = url
+}
+
 func (d *Connector) GetToken() string {
 	d.mutex.Lock()
 	defer d.mutex.Unlock()
@@ -61,12 +67,24 @@ func (d *Connector) GetConnectionTimeout() time.Duration {
 	return d.connectionTimeout
 }
 
+func (d *Connector) SetConnectionTimeout(connectionTimeout time.Duration) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.connectionTimeout = connectionTimeout
+}
+
 func (d *Connector) GetRefreshTime() time.Duration {
 	d.mutex.Lock()
 	defer d.mutex.Unlock()
 	return d.refreshTime
 }
 
+func (d *Connector) SetRefreshTime(refreshTime time.Duration) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.refreshTime = refreshTime
+}
+
 func NewConnector(filesURI, url url.URL, token string, refresh,
 	connectionTimeout time.Duration) *Connector {
 	return &Connector{
@@ -2,15 +2,19 @@ package departures
 
 import (
 	"fmt"
+	"io"
+	"net/url"
 	"sort"
 	"sync"
 	"time"
+
+	"github.com/CanalTP/forseti/internal/utils"
 )
 
 type DeparturesContext struct {
-	departures          *map[string][]Departure
-	lastDepartureUpdate time.Time
-	departuresMutex     sync.RWMutex
+	utils.FileState
+	departures      *map[string][]Departure
+	departuresMutex sync.RWMutex
 }
 
 func (d *DeparturesContext) UpdateDepartures(departures map[string][]Departure) {
@@ -18,14 +22,25 @@ func (d *DeparturesContext) UpdateDepartures(departures map[string][]Departure)
 	defer d.departuresMutex.Unlock()
 
 	d.departures = &departures
-	d.lastDepartureUpdate = time.Now()
+	d.MarkUpdated()
 }
 
 func (d *DeparturesContext) GetLastDepartureDataUpdate() time.Time {
+	return d.GetLastUpdate()
+}
+
+// GetDeparturesCount returns the number of departures of all the stops
+func (d *DeparturesContext) GetDeparturesCount() int {
 	d.departuresMutex.RLock()
 	defer d.departuresMutex.RUnlock()
 
-	return d.lastDepartureUpdate
+	count := 0
+	if d.departures != nil {
+		for _, departures := range *d.departures {
+			count += len(departures)
+		}
+	}
+	return count
 }
 
 func (d *DeparturesContext) GetDeparturesByStops(stopsID []string) ([]Departure, error) {
@@ -75,3 +90,37 @@ func filterDeparturesByDirectionType(departures []Departure, directionType Direc
 	}
 	return departures[:n]
 }
+
+// RefreshFile loads the file of the departures if it has been modified since its last load
+func (d *DeparturesContext) RefreshFile(uri url.URL, connectionTimeout time.Duration) error {
+	return RefreshDepartures(d, uri, connectionTimeout)
+}
+
+// LoadPayload loads the departures pushed by their provider
+func (d *DeparturesContext) LoadPayload(r io.Reader) error {
+	return IngestDepartures(d, r)
+}
+
+// WriteSnapshot writes the departures with the time of their last update
+func (d *DeparturesContext) WriteSnapshot(w io.Writer) error {
+	d.departuresMutex.RLock()
+	defer d.departuresMutex.RUnlock()
+
+	if d.departures == nil {
+		return fmt.Errorf("no departures to save")
+	}
+	return d.FileState.WriteSnapshot(w, *d.departures)
+}
+
+// ReadSnapshot replaces the departures by the ones of a snapshot, with their time of update
+func (d *DeparturesContext) ReadSnapshot(r io.Reader) error {
+	var departures map[string][]Departure
+	if err := d.FileState.ReadSnapshot(r, &departures); err != nil {
+		return err
+	}
+
+	d.departuresMutex.Lock()
+	defer d.departuresMutex.Unlock()
+	d.departures = &departures
+	return nil
+}
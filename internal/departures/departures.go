@@ -1,6 +1,7 @@
 package departures
 
 import (
+	"io"
 	"net/url"
 	"time"
 
@@ -9,38 +10,72 @@ import (
 	"github.com/CanalTP/forseti/internal/utils"
 )
 
-func RefreshDeparturesLoop(context *DeparturesContext,
-	departuresURI url.URL,
-	departuresRefresh, connectionTimeout time.Duration) {
-	if len(departuresURI.String()) == 0 || departuresRefresh.Seconds() <= 0 {
-		logrus.Debug("Departures data refreshing is disabled")
-		return
-	}
-	for {
-		err := RefreshDepartures(context, departuresURI, connectionTimeout)
-		if err != nil {
-			logrus.Error("Error while reloading departures data: ", err)
-		} else {
-			logrus.Debug("Departures data updated")
-		}
-		time.Sleep(departuresRefresh)
-	}
+// layout is the default layout of the file of the departures, without header
+var layout = utils.Layout{
+	Fields:     []string{"stop", "line", "direction_name", "", "type", "datetime", "direction", "", "", "direction_type"},
+	DateFields: []string{"datetime"},
+}
+
+var defaultLoadDataOptions = utils.LoadDataOptions{
+	Delimiter:     ';',
+	NbFields:      0, // do not check record size in csv.reader
+	SkipFirstLine: false,
 }
 
 func RefreshDepartures(context *DeparturesContext, uri url.URL, connectionTimeout time.Duration) error {
 	begin := time.Now()
-	file, err := utils.GetFile(uri, connectionTimeout)
+	file, err := utils.GetFileIfModified(uri, connectionTimeout, context.GetFileVersion())
+	if err == utils.ErrNotModified {
+		context.MarkUnchanged()
+		DepartureSkippedReloads.Inc()
+		return nil
+	}
 	if err != nil {
 		DepartureLoadingErrors.Inc()
 		return err
 	}
+	defer file.Close()
 
 	departureConsumer := makeDepartureLineConsumer()
-	if err = utils.LoadData(file, departureConsumer); err != nil {
+	options := context.GetLoadDataOptions(defaultLoadDataOptions)
+	report, err := utils.LoadDataWithReport(file, departureConsumer, options)
+	context.SetLoadReport(report)
+	DepartureSkippedRecords.Add(float64(report.Skipped))
+	if err != nil {
+		DepartureLoadingErrors.Inc()
+		return err
+	}
+	if err = file.Done(); err != nil {
+		logrus.Errorf("Impossible to archive or delete the file of the departures: %s", err)
+	}
+	version := file.Version()
+	if version.Checksum == context.GetFileVersion().Checksum {
+		// the file has been written again with the same content
+		context.MarkUnchanged()
+		context.SetFileVersion(version)
+		DepartureSkippedReloads.Inc()
+		return nil
+	}
+	context.UpdateDepartures(departureConsumer.data)
+	context.SetFileVersion(version)
+	DepartureLoadingDuration.Observe(time.Since(begin).Seconds())
+	return nil
+}
+
+// IngestDepartures loads the departures pushed by their provider, in the format of the file of the departures
+func IngestDepartures(context *DeparturesContext, payload io.Reader) error {
+	begin := time.Now()
+	departureConsumer := makeDepartureLineConsumer()
+	options := context.GetLoadDataOptions(defaultLoadDataOptions)
+	report, err := utils.LoadDataWithReport(payload, departureConsumer, options)
+	context.SetLoadReport(report)
+	DepartureSkippedRecords.Add(float64(report.Skipped))
+	if err != nil {
 		DepartureLoadingErrors.Inc()
 		return err
 	}
 	context.UpdateDepartures(departureConsumer.data)
+	context.MarkPushed()
 	DepartureLoadingDuration.Observe(time.Since(begin).Seconds())
 	return nil
 }
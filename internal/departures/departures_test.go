@@ -1,16 +1,23 @@
 package departures
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 
+	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"testing"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -237,6 +244,149 @@ func TestRefreshData(t *testing.T) {
 	checkSecond(t, departures)
 }
 
+func TestRefreshDataWithHTTP(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("If-None-Match") == `"first"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"first"`)
+		http.ServeFile(w, r, fmt.Sprintf("%s/first.txt", fixtureDir))
+	}))
+	defer server.Close()
+	uri, err := url.Parse(server.URL + "/first.txt")
+	require.Nil(t, err)
+
+	departuresContext := &DeparturesContext{}
+	err = RefreshDepartures(departuresContext, *uri, defaultTimeout)
+	require.Nil(t, err)
+	lastUpdate := departuresContext.GetLastDepartureDataUpdate()
+
+	// an unchanged file is not loaded again, the departures are kept and marked as updated
+	err = RefreshDepartures(departuresContext, *uri, defaultTimeout)
+	require.Nil(t, err)
+	assert.Equal(t, 2, requests)
+	assert.True(t, departuresContext.GetLastDepartureDataUpdate().After(lastUpdate))
+	departures, err := departuresContext.GetDeparturesByStops([]string{"3"})
+	require.Nil(t, err)
+	checkFirst(t, departures)
+}
+
+func TestRefreshDataSkipsUnchangedFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "departures")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "departures.txt")
+	content, err := ioutil.ReadFile(fmt.Sprintf("%s/first.txt", fixtureDir))
+	require.Nil(t, err)
+	require.Nil(t, ioutil.WriteFile(path, content, 0600))
+	uri, err := url.Parse("file://" + path)
+	require.Nil(t, err)
+
+	departuresContext := &DeparturesContext{}
+	require.Nil(t, RefreshDepartures(departuresContext, *uri, defaultTimeout))
+	skipped := counterValue(t, DepartureSkippedReloads)
+
+	// the departures of an unchanged file are kept, even if the file has been written again
+	require.Nil(t, RefreshDepartures(departuresContext, *uri, defaultTimeout))
+	modTime := time.Now().Add(time.Minute)
+	require.Nil(t, os.Chtimes(path, modTime, modTime))
+	require.Nil(t, RefreshDepartures(departuresContext, *uri, defaultTimeout))
+	assert.Equal(t, skipped+2, counterValue(t, DepartureSkippedReloads))
+	departures, err := departuresContext.GetDeparturesByStops([]string{"3"})
+	require.Nil(t, err)
+	checkFirst(t, departures)
+
+	content, err = ioutil.ReadFile(fmt.Sprintf("%s/second.txt", fixtureDir))
+	require.Nil(t, err)
+	require.Nil(t, ioutil.WriteFile(path, content, 0600))
+	require.Nil(t, RefreshDepartures(departuresContext, *uri, defaultTimeout))
+	assert.Equal(t, skipped+2, counterValue(t, DepartureSkippedReloads))
+	departures, err = departuresContext.GetDeparturesByStops([]string{"3"})
+	require.Nil(t, err)
+	checkSecond(t, departures)
+}
+
+func TestRefreshDataKeepsLastUpdateOfPush(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	firstURI, err := url.Parse(fmt.Sprintf("file://%s/first.txt", fixtureDir))
+	require.Nil(err)
+	departuresContext := &DeparturesContext{}
+	require.Nil(RefreshDepartures(departuresContext, *firstURI, defaultTimeout))
+
+	second, err := os.Open(fmt.Sprintf("%s/second.txt", fixtureDir))
+	require.Nil(err)
+	defer second.Close()
+	require.Nil(IngestDepartures(departuresContext, second))
+	pushed := departuresContext.GetLastUpdate()
+
+	// the unchanged file does not mark the pushed departures as up to date
+	time.Sleep(time.Millisecond)
+	require.Nil(RefreshDepartures(departuresContext, *firstURI, defaultTimeout))
+	assert.Equal(pushed, departuresContext.GetLastUpdate())
+	departures, err := departuresContext.GetDeparturesByStops([]string{"3"})
+	require.Nil(err)
+	checkSecond(t, departures)
+
+	// a new file replaces the pushed departures
+	multipleURI, err := url.Parse(fmt.Sprintf("file://%s/multiple.txt", fixtureDir))
+	require.Nil(err)
+	require.Nil(RefreshDepartures(departuresContext, *multipleURI, defaultTimeout))
+	loaded := departuresContext.GetLastUpdate()
+	assert.True(loaded.After(pushed))
+	time.Sleep(time.Millisecond)
+	require.Nil(RefreshDepartures(departuresContext, *multipleURI, defaultTimeout))
+	assert.True(departuresContext.GetLastUpdate().After(loaded))
+}
+
+func TestRefreshDataWithDelete(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "departures")
+	require.Nil(err)
+	defer os.RemoveAll(dir)
+	content, err := ioutil.ReadFile(fmt.Sprintf("%s/first.txt", fixtureDir))
+	require.Nil(err)
+
+	// a file given by its name is kept, the next refreshes do not fail
+	path := filepath.Join(dir, "departures.txt")
+	require.Nil(ioutil.WriteFile(path, content, 0600))
+	uri, err := url.Parse("file://" + path + "?delete=true")
+	require.Nil(err)
+	departuresContext := &DeparturesContext{}
+	require.Nil(RefreshDepartures(departuresContext, *uri, defaultTimeout))
+	require.Nil(RefreshDepartures(departuresContext, *uri, defaultTimeout))
+	assert.FileExists(path)
+
+	// no file has been dropped yet
+	uri, err = url.Parse("file://" + dir + "/extract_*.txt?delete=true")
+	require.Nil(err)
+	departuresContext = &DeparturesContext{}
+	require.Nil(RefreshDepartures(departuresContext, *uri, defaultTimeout))
+	assert.True(departuresContext.GetLastDepartureDataUpdate().IsZero())
+
+	// the dropped file is deleted, its departures are kept by the next refresh
+	require.Nil(ioutil.WriteFile(filepath.Join(dir, "extract_1.txt"), content, 0600))
+	require.Nil(RefreshDepartures(departuresContext, *uri, defaultTimeout))
+	_, err = os.Stat(filepath.Join(dir, "extract_1.txt"))
+	assert.True(os.IsNotExist(err))
+	require.Nil(RefreshDepartures(departuresContext, *uri, defaultTimeout))
+	departures, err := departuresContext.GetDeparturesByStops([]string{"3"})
+	require.Nil(err)
+	checkFirst(t, departures)
+}
+
+func counterValue(t *testing.T, counter prometheus.Counter) float64 {
+	var metric dto.Metric
+	require.Nil(t, counter.Write(&metric))
+	return metric.GetCounter().GetValue()
+}
+
 func TestMultipleStopsID(t *testing.T) {
 	firstURI, err := url.Parse(fmt.Sprintf("file://%s/multiple.txt", fixtureDir))
 	require.Nil(t, err)
@@ -429,11 +579,38 @@ func TestDataManagerLastUpdate(t *testing.T) {
 	departuresContext := &DeparturesContext{}
 	departuresContext.UpdateDepartures(nil)
 
-	lastDepartureUpdate := departuresContext.lastDepartureUpdate
+	lastDepartureUpdate := departuresContext.GetLastDepartureDataUpdate()
 	require.True(lastDepartureUpdate.After(begin))
 
 	departuresContext.UpdateDepartures(nil)
-	require.True(departuresContext.lastDepartureUpdate.After(lastDepartureUpdate))
+	require.True(departuresContext.GetLastDepartureDataUpdate().After(lastDepartureUpdate))
+}
+
+func TestDeparturesSnapshot(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	datetime := time.Date(2021, 6, 1, 10, 30, 0, 0, time.UTC)
+	departuresContext := &DeparturesContext{}
+	var buffer bytes.Buffer
+	require.Error(departuresContext.WriteSnapshot(&buffer))
+
+	departuresContext.UpdateDepartures(map[string][]Departure{
+		"3": {{Line: "40", Stop: "3", Type: "E", Direction: "1", DirectionName: "Campus", Datetime: datetime,
+			DirectionType: DirectionTypeBackward}},
+	})
+	require.Nil(departuresContext.WriteSnapshot(&buffer))
+
+	restored := &DeparturesContext{}
+	require.Nil(restored.ReadSnapshot(&buffer))
+	assert.Equal(departuresContext.GetLastDepartureDataUpdate().UnixNano(),
+		restored.GetLastDepartureDataUpdate().UnixNano())
+	departures, err := restored.GetDeparturesByStops([]string{"3"})
+	require.Nil(err)
+	require.Len(departures, 1)
+	assert.Equal("Campus", departures[0].DirectionName)
+	assert.True(datetime.Equal(departures[0].Datetime))
+	assert.Equal(DirectionTypeBackward, departures[0].DirectionType)
 }
 
 func TestParseDirectionType(t *testing.T) {
@@ -480,3 +657,90 @@ func TestKeepDirection(t *testing.T) {
 	assert.True(keepDirection(DirectionTypeUnknown, DirectionTypeForward))
 	assert.True(keepDirection(DirectionTypeUnknown, DirectionTypeBoth))
 }
+
+func TestRefreshMappedDepartures(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	config := viper.New()
+	config.Set("uri", fmt.Sprintf("file://%s/departures_mapped.csv", fixtureDir))
+	config.Set("refresh", time.Minute)
+	config.Set("csv-columns", "stop=ARRET,line=LIGNE,direction_name=NOM_DIRECTION,type=TYPE,datetime=HEURE,"+
+		"direction=DIRECTION")
+	config.Set("csv-date-format", time.RFC3339)
+	config.Set("csv-delimiter", ",")
+	s, err := NewSourceFromConfig("", config)
+	require.Nil(err)
+	source := s.(*DeparturesSource)
+	require.Nil(source.Load())
+
+	departures, err := source.GetContext().GetDeparturesByStops([]string{"3"})
+	require.Nil(err)
+	checkFirst(t, departures)
+	assert.Equal("Francheville Taffignon", departures[0].DirectionName)
+	assert.Equal("C20A", departures[0].Line)
+	assert.Equal("E", departures[0].Type)
+	assert.Equal("367", departures[0].Direction)
+
+	// an invalid layout is rejected
+	config.Set("csv-columns", "platform=4")
+	assert.EqualError(source.Reload(config), "invalid layout of the departures file: unknown field platform, "+
+		"expected one of: stop, line, direction_name, type, datetime, direction, direction_type")
+	config.Set("csv-columns", "")
+	config.Set("csv-delimiter", ";;")
+	_, err = NewSourceFromConfig("", config)
+	assert.Error(err)
+}
+
+func TestRefreshDeparturesSkipsInvalidRecords(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	config := viper.New()
+	config.Set("uri", fmt.Sprintf("file://%s/invaliddate.txt", fixtureDir))
+	config.Set("refresh", time.Minute)
+	config.Set("csv-max-error-ratio", 0.25)
+	s, err := NewSourceFromConfig("", config)
+	require.Nil(err)
+	source := s.(*DeparturesSource)
+	skipped := counterValue(t, DepartureSkippedRecords)
+	require.Nil(source.Refresh())
+
+	// the departure with an invalid date is skipped
+	departures, err := source.GetContext().GetDeparturesByStops([]string{"3"})
+	require.Nil(err)
+	assert.Len(departures, 3)
+	assert.Equal(skipped+1, counterValue(t, DepartureSkippedRecords))
+	report := source.GetDetails().LastLoad
+	require.NotNil(report)
+	assert.Equal(4, report.Records)
+	assert.Equal(1, report.Skipped)
+	require.Len(report.Errors, 1)
+	assert.Equal(1, report.Errors[0].Line)
+
+	// the file is rejected above the maximum ratio
+	config.Set("csv-max-error-ratio", 0.2)
+	require.Nil(source.Reload(config))
+	assert.Error(source.Refresh())
+	departures, err = source.GetContext().GetDeparturesByStops([]string{"3"})
+	require.Nil(err)
+	assert.Len(departures, 3)
+	// the records of a rejected file are counted
+	assert.Equal(skipped+2, counterValue(t, DepartureSkippedRecords))
+
+	// the report of a rejected push replaces the one of the last file
+	payload, err := os.Open(fmt.Sprintf("%s/first.txt", fixtureDir))
+	require.Nil(err)
+	defer payload.Close()
+	require.Nil(source.LoadPayload(payload))
+	assert.Equal(0, source.LoadReport().Skipped)
+	payload, err = os.Open(fmt.Sprintf("%s/invaliddate.txt", fixtureDir))
+	require.Nil(err)
+	defer payload.Close()
+	assert.Error(source.LoadPayload(payload))
+	assert.Equal(1, source.LoadReport().Skipped)
+	assert.Equal(skipped+3, counterValue(t, DepartureSkippedRecords))
+
+	config.Set("csv-max-error-ratio", 2)
+	assert.Error(source.Reload(config))
+}
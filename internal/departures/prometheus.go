@@ -19,4 +19,18 @@ var (
 		Name:      "loading_errors",
 		Help:      "current number of http request being served",
 	})
+
+	DepartureSkippedReloads = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "forseti",
+		Subsystem: "departures",
+		Name:      "skipped_reloads",
+		Help:      "number of refreshes skipped because the file has not changed",
+	})
+
+	DepartureSkippedRecords = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "forseti",
+		Subsystem: "departures",
+		Name:      "skipped_records",
+		Help:      "number of invalid records skipped in the loaded files",
+	})
 )
@@ -2,14 +2,18 @@ package equipments
 
 import (
 	"fmt"
+	"io"
+	"net/url"
 	"sync"
 	"time"
+
+	"github.com/CanalTP/forseti/internal/utils"
 )
 
 type EquipmentsContext struct {
-	equipments          *[]EquipmentDetail
-	lastEquipmentUpdate time.Time
-	equipmentsMutex     sync.RWMutex
+	utils.FileState
+	equipments      *[]EquipmentDetail
+	equipmentsMutex sync.RWMutex
 }
 
 func (d *EquipmentsContext) GetEquipments() (equipments []EquipmentDetail, e error) {
@@ -34,12 +38,53 @@ func (d *EquipmentsContext) UpdateEquipments(equipments []EquipmentDetail) {
 	defer d.equipmentsMutex.Unlock()
 
 	d.equipments = &equipments
-	d.lastEquipmentUpdate = time.Now()
+	d.MarkUpdated()
 }
 
 func (d *EquipmentsContext) GetLastEquipmentsDataUpdate() time.Time {
+	return d.GetLastUpdate()
+}
+
+func (d *EquipmentsContext) GetEquipmentsCount() int {
 	d.equipmentsMutex.RLock()
 	defer d.equipmentsMutex.RUnlock()
 
-	return d.lastEquipmentUpdate
+	if d.equipments == nil {
+		return 0
+	}
+	return len(*d.equipments)
+}
+
+// RefreshFile loads the file of the equipments if it has been modified since its last load
+func (d *EquipmentsContext) RefreshFile(uri url.URL, connectionTimeout time.Duration) error {
+	return RefreshEquipments(d, uri, connectionTimeout)
+}
+
+// LoadPayload loads the equipments pushed by their provider
+func (d *EquipmentsContext) LoadPayload(r io.Reader) error {
+	return IngestEquipments(d, r)
+}
+
+// WriteSnapshot writes the equipments with the time of their last update
+func (d *EquipmentsContext) WriteSnapshot(w io.Writer) error {
+	d.equipmentsMutex.RLock()
+	defer d.equipmentsMutex.RUnlock()
+
+	if d.equipments == nil {
+		return fmt.Errorf("no equipments to save")
+	}
+	return d.FileState.WriteSnapshot(w, *d.equipments)
+}
+
+// ReadSnapshot replaces the equipments by the ones of a snapshot, with their time of update
+func (d *EquipmentsContext) ReadSnapshot(r io.Reader) error {
+	var equipments []EquipmentDetail
+	if err := d.FileState.ReadSnapshot(r, &equipments); err != nil {
+		return err
+	}
+
+	d.equipmentsMutex.Lock()
+	defer d.equipmentsMutex.Unlock()
+	d.equipments = &equipments
+	return nil
 }
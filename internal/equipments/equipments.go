@@ -1,7 +1,6 @@
 package equipments
 
 import (
-	"bytes"
 	"encoding/xml"
 	"fmt"
 	"io"
@@ -18,39 +17,57 @@ import (
 
 var location = "Europe/Paris"
 
-// Main loop
-func RefreshEquipmentLoop(context *EquipmentsContext, equipmentsURI url.URL,
-	equipmentsRefresh, connectionTimeout time.Duration) {
-	if len(equipmentsURI.String()) == 0 || equipmentsRefresh.Seconds() <= 0 {
-		logrus.Debug("Equipment data refreshing is disabled")
-		return
-	}
-	for {
-		err := RefreshEquipments(context, equipmentsURI, connectionTimeout)
-		if err != nil {
-			logrus.Error("Error while reloading equipment data: ", err)
-		} else {
-			logrus.Debug("Equipment data updated")
-		}
-		time.Sleep(equipmentsRefresh)
-	}
-}
-
 func RefreshEquipments(context *EquipmentsContext, uri url.URL, connectionTimeout time.Duration) error {
 	begin := time.Now()
-	file, err := utils.GetFile(uri, connectionTimeout)
-
+	file, err := utils.GetFileIfModified(uri, connectionTimeout, context.GetFileVersion())
+	if err == utils.ErrNotModified {
+		context.MarkUnchanged()
+		EquipmentsSkippedReloads.Inc()
+		return nil
+	}
 	if err != nil {
 		EquipmentsLoadingErrors.Inc()
 		return err
 	}
+	defer file.Close()
 
 	equipments, err := loadXmlEquipments(file)
 	if err != nil {
 		EquipmentsLoadingErrors.Inc()
 		return err
 	}
+	// the end of the file after the root element is read for its checksum
+	if _, err = io.Copy(ioutil.Discard, file); err != nil {
+		EquipmentsLoadingErrors.Inc()
+		return err
+	}
+	if err = file.Done(); err != nil {
+		logrus.Errorf("Impossible to archive or delete the file of the equipments: %s", err)
+	}
+	version := file.Version()
+	if version.Checksum == context.GetFileVersion().Checksum {
+		// the file has been written again with the same content
+		context.MarkUnchanged()
+		context.SetFileVersion(version)
+		EquipmentsSkippedReloads.Inc()
+		return nil
+	}
+	context.UpdateEquipments(equipments)
+	context.SetFileVersion(version)
+	EquipmentsLoadingDuration.Observe(time.Since(begin).Seconds())
+	return nil
+}
+
+// IngestEquipments loads the equipments pushed by their provider, in the format of the file of the equipments
+func IngestEquipments(context *EquipmentsContext, payload io.Reader) error {
+	begin := time.Now()
+	equipments, err := loadXmlEquipments(payload)
+	if err != nil {
+		EquipmentsLoadingErrors.Inc()
+		return err
+	}
 	context.UpdateEquipments(equipments)
+	context.MarkPushed()
 	EquipmentsLoadingDuration.Observe(time.Since(begin).Seconds())
 	return nil
 }
@@ -62,11 +79,7 @@ func loadXmlEquipments(file io.Reader) ([]EquipmentDetail, error) {
 		return nil, err
 	}
 
-	XMLdata, err := ioutil.ReadAll(file)
-	if err != nil {
-		return nil, err
-	}
-	decoder := xml.NewDecoder(bytes.NewReader(XMLdata))
+	decoder := xml.NewDecoder(file)
 	decoder.CharsetReader = getCharsetReader
 
 	var root data.Root
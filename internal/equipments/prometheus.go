@@ -19,4 +19,11 @@ var (
 		Name:      "loading_errors",
 		Help:      "current number of http request being served",
 	})
+
+	EquipmentsSkippedReloads = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "forseti",
+		Subsystem: "equipments",
+		Name:      "skipped_reloads",
+		Help:      "number of refreshes skipped because the file has not changed",
+	})
 )
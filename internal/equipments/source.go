@@ -0,0 +1,59 @@
+package equipments
+
+import (
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+
+	"github.com/CanalTP/forseti/internal/sources"
+)
+
+const ModuleName = "equipments"
+
+var module = sources.FileModule{
+	Name: ModuleName,
+	Data: "equipments",
+}
+
+func init() {
+	sources.Register(sources.Module{
+		Name:       ModuleName,
+		Parameters: module.Parameters(),
+		New:        NewSourceFromConfig,
+	})
+}
+
+// EquipmentsSource serves the equipments read periodically from a xml file
+type EquipmentsSource struct {
+	*sources.FileSource
+	context *EquipmentsContext
+}
+
+func NewEquipmentsSource(context *EquipmentsContext, uri url.URL,
+	refresh, connectionTimeout time.Duration) *EquipmentsSource {
+	source := &EquipmentsSource{context: context}
+	source.FileSource = sources.NewFileSource(source, module, context, uri, refresh, connectionTimeout)
+	return source
+}
+
+func NewSourceFromConfig(instance string, config *viper.Viper) (sources.Source, error) {
+	source := NewEquipmentsSource(&EquipmentsContext{}, url.URL{}, 0, 0)
+	if configured, err := source.Configure(instance, config); !configured {
+		return nil, err
+	}
+	return source, nil
+}
+
+func (s *EquipmentsSource) GetContext() *EquipmentsContext {
+	return s.context
+}
+
+func (s *EquipmentsSource) AddEntryPoints(r *gin.Engine) {
+	AddEquipmentsEntryPoint(r, s.context)
+}
+
+func (s *EquipmentsSource) LoadedItems() int {
+	return s.context.GetEquipmentsCount()
+}
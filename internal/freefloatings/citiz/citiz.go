@@ -2,18 +2,24 @@ package citiz
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
 	"net/url"
-	"reflect"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/CanalTP/forseti/internal/breaker"
 	"github.com/CanalTP/forseti/internal/connectors"
 	"github.com/CanalTP/forseti/internal/freefloatings"
 	"github.com/CanalTP/forseti/internal/utils"
 	"github.com/sirupsen/logrus"
 )
 
+// errTokenRejected is returned when a provider rejects the token, a new token is then asked at the next refresh
+var errTokenRejected = errors.New("token rejected")
+
 type CitizContext struct {
 	connector *connectors.Connector
 	providers []string // list of providers to get vehicles free-floatings
@@ -22,6 +28,16 @@ type CitizContext struct {
 	password  string // password to get token
 }
 
+func init() {
+	freefloatings.RegisterConnector(string(connectors.Connector_CITIZ),
+		func(uri url.URL, config freefloatings.Config) freefloatings.Connector {
+			var c = CitizContext{}
+			c.InitContext(uri, config.Refresh, config.Providers, config.ConnectionTimeout, config.UserName,
+				config.Password)
+			return &c
+		})
+}
+
 func (d *CitizContext) InitContext(externalURI url.URL, loadExternalRefresh time.Duration, providers []string,
 	connectionTimeout time.Duration, userName, password string) {
 
@@ -32,43 +48,43 @@ func (d *CitizContext) InitContext(externalURI url.URL, loadExternalRefresh time
 	d.password = password
 }
 
-func (d *CitizContext) RefreshFreeFloatingLoop(context *freefloatings.FreeFloatingsContext) {
-
-	context.SetPackageName(reflect.TypeOf(CitizContext{}).PkgPath())
-	context.RefreshTime = d.connector.GetRefreshTime()
-
-	// Wait 10 seconds before reloading external freefloating informations
-	time.Sleep(10 * time.Second)
-	for {
-
-		if d.auth.Token == "" || tokenExpired(d.auth.Expire_in) {
-			auth, err := utils.GetAuthToken(d.connector.GetUrl(), d.user, d.password, d.connector.GetConnectionTimeout())
-			if err != nil {
-				return
-			}
-			auth.Expire_in = int(time.Now().Add(1 * time.Hour).Unix()) // Just for beta test
-			d.auth = auth
-			d.connector.SetToken(auth.Token)
+// Load gets a token if needed then refreshes the free-floatings of all the providers. A failed authentication
+// is retried at the next refresh.
+func (d *CitizContext) Load(context *freefloatings.FreeFloatingsContext) error {
+	if d.auth.Token == "" || tokenExpired(d.auth.Expire_in) {
+		var auth *utils.OAuthResponse
+		err := breaker.Call("citiz:"+d.connector.GetUrl().Host, func() (err error) {
+			auth, err = utils.GetAuthToken(d.connector.GetUrl(), d.user, d.password,
+				d.connector.GetConnectionTimeout())
+			return err
+		})
+		if err != nil {
+			return err
 		}
+		if auth.Token == "" {
+			return fmt.Errorf("no token received from %s", d.connector.GetUrl().Host)
+		}
+		auth.Expire_in = int(time.Now().Add(1 * time.Hour).Unix()) // Just for beta test
+		d.auth = auth
+		d.connector.SetToken(auth.Token)
+	}
 
-		err := RefreshFreeFloatings(d, context)
-		if err != nil {
-			logrus.Error("Error while reloading freefloating data: ")
-			for _, e := range err {
-				logrus.Error(fmt.Sprintf("\t- %s", e))
+	errs := RefreshFreeFloatings(d, context)
+	if len(errs) > 0 {
+		messages := make([]string, 0, len(errs))
+		for _, e := range errs {
+			if errors.Is(e, errTokenRejected) {
+				// the token has been revoked, a new one is asked at the next refresh
+				d.auth = &utils.OAuthResponse{}
 			}
-		} else {
-			logrus.Debug("Free_floating data updated")
+			messages = append(messages, e.Error())
 		}
-		time.Sleep(d.connector.GetRefreshTime())
+		return fmt.Errorf("%s", strings.Join(messages, ", "))
 	}
+	return nil
 }
 
 func RefreshFreeFloatings(citizContext *CitizContext, context *freefloatings.FreeFloatingsContext) []error {
-	// Continue using last loaded data if loading is deactivated
-	if !context.LoadFreeFloatingsData() {
-		return nil
-	}
 	begin := time.Now()
 	var err []error
 
@@ -97,20 +113,27 @@ func LoadDatafromConnector(connector *connectors.Connector,
 	for _, provider := range providers {
 
 		callUrl := fmt.Sprintf("%s/api/provider/%s/extended-vehicles?refresh=true", urlPath.String(), provider)
-		resp, e := utils.GetHttpClient(callUrl, token, "Authorization", connector.GetConnectionTimeout())
+		var resp *http.Response
+		e := breaker.Call("citiz:"+urlPath.Host+":"+provider, func() (e error) {
+			resp, e = utils.GetHttpClient(callUrl, token, "Authorization", connector.GetConnectionTimeout())
+			if e != nil {
+				return e
+			}
+			if resp.StatusCode == http.StatusUnauthorized {
+				resp.Body.Close()
+				return fmt.Errorf("error with provider %s: %w", provider, errTokenRejected)
+			}
+			if e = utils.CheckResponseStatus(resp); e != nil {
+				return fmt.Errorf("error with provider %s", provider)
+			}
+			return nil
+		})
 		if e != nil {
 			freefloatings.FreeFloatingsLoadingErrors.Inc()
 			err = append(err, e)
 			continue
 		}
 
-		e = utils.CheckResponseStatus(resp)
-		if e != nil {
-			freefloatings.FreeFloatingsLoadingErrors.Inc()
-			err = append(err, fmt.Errorf("error with provider %s", provider))
-			continue
-		}
-
 		vehicles := &CitizData{}
 		decoder := json.NewDecoder(resp.Body)
 		e = decoder.Decode(vehicles)
@@ -2,12 +2,18 @@ package citiz
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"os"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/CanalTP/forseti/internal/breaker"
 	"github.com/CanalTP/forseti/internal/freefloatings"
 	"github.com/CanalTP/forseti/internal/utils"
 	"github.com/stretchr/testify/assert"
@@ -160,3 +166,126 @@ func TestDataManagerGetFreeFloatings(t *testing.T) {
 	assert.Equal("FG-335-PN", free_floatings[1].PublicId)
 	assert.Equal("3829", free_floatings[1].Id)
 }
+
+func TestLoadRecoversFromAuthenticationFailure(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	vehicles, err := ioutil.ReadFile(fmt.Sprintf("%s/vehicles_citiz.json", fixtureDir))
+	require.Nil(err)
+	var authenticated int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/authentication" && atomic.LoadInt32(&authenticated) == 0:
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{}`))
+		case r.URL.Path == "/authentication":
+			_, _ = w.Write([]byte(`{"access_token": "token", "token_type": "bearer", "expires_in": 3600}`))
+		case r.Header.Get("Authorization") == "Bearer token":
+			_, _ = w.Write(vehicles)
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+	uri, err := url.Parse(server.URL)
+	require.Nil(err)
+
+	var citiz CitizContext
+	citiz.InitContext(*uri, time.Minute, []string{"19"}, time.Second, "user", "password")
+	context := &freefloatings.FreeFloatingsContext{}
+
+	// the authentication is retried at each load
+	assert.Error(citiz.Load(context))
+	assert.Error(citiz.Load(context))
+	atomic.StoreInt32(&authenticated, 1)
+	assert.Nil(citiz.Load(context))
+	assert.Equal(6, context.GetFreeFloatingsCount())
+}
+
+func TestLoadKeepsTokenOnProviderFailure(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	vehicles, err := ioutil.ReadFile(fmt.Sprintf("%s/vehicles_citiz.json", fixtureDir))
+	require.Nil(err)
+	var authentications, valid int32 = 0, 1
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/authentication":
+			n := atomic.AddInt32(&authentications, 1)
+			_, _ = fmt.Fprintf(w, `{"access_token": "token%d", "token_type": "bearer", "expires_in": 3600}`, n)
+		case r.URL.Path == "/api/provider/broken/extended-vehicles":
+			w.WriteHeader(http.StatusInternalServerError)
+		case r.Header.Get("Authorization") == fmt.Sprintf("Bearer token%d", atomic.LoadInt32(&valid)):
+			_, _ = w.Write(vehicles)
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+	uri, err := url.Parse(server.URL)
+	require.Nil(err)
+
+	var citiz CitizContext
+	citiz.InitContext(*uri, time.Minute, []string{"working", "broken"}, time.Second, "user", "password")
+	context := &freefloatings.FreeFloatingsContext{}
+
+	// a broken provider does not renew the token
+	assert.Error(citiz.Load(context))
+	assert.Error(citiz.Load(context))
+	assert.Equal(int32(1), atomic.LoadInt32(&authentications))
+	assert.Equal(6, context.GetFreeFloatingsCount())
+
+	// a rejected token is renewed at the next load
+	atomic.StoreInt32(&valid, 2)
+	assert.Error(citiz.Load(context))
+	assert.Equal(int32(1), atomic.LoadInt32(&authentications))
+	assert.Error(citiz.Load(context))
+	assert.Equal(int32(2), atomic.LoadInt32(&authentications))
+	assert.Equal(6, context.GetFreeFloatingsCount())
+}
+
+func TestLoadAuthenticationBreakerByHost(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	vehicles, err := ioutil.ReadFile(fmt.Sprintf("%s/vehicles_citiz.json", fixtureDir))
+	require.Nil(err)
+	var authentications int32
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&authentications, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer broken.Close()
+	working := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/authentication" {
+			_, _ = w.Write([]byte(`{"access_token": "token", "token_type": "bearer", "expires_in": 3600}`))
+			return
+		}
+		_, _ = w.Write(vehicles)
+	}))
+	defer working.Close()
+
+	brokenURI, err := url.Parse(broken.URL)
+	require.Nil(err)
+	var brokenCitiz CitizContext
+	brokenCitiz.InitContext(*brokenURI, time.Minute, []string{"provider"}, time.Second, "user", "password")
+	context := &freefloatings.FreeFloatingsContext{}
+
+	// the authentication failures open the breaker of the host
+	for i := 0; i < breaker.DefaultThreshold; i++ {
+		assert.Error(brokenCitiz.Load(context))
+	}
+	err = brokenCitiz.Load(context)
+	assert.True(errors.Is(err, breaker.ErrOpen))
+	assert.Equal(int32(breaker.DefaultThreshold), atomic.LoadInt32(&authentications))
+
+	// another host is not affected
+	workingURI, err := url.Parse(working.URL)
+	require.Nil(err)
+	var workingCitiz CitizContext
+	workingCitiz.InitContext(*workingURI, time.Minute, []string{"provider"}, time.Second, "user", "password")
+	assert.Nil(workingCitiz.Load(context))
+	assert.Equal(6, context.GetFreeFloatingsCount())
+}
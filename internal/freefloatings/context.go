@@ -1,7 +1,9 @@
 package freefloatings
 
 import (
+	"encoding/gob"
 	"fmt"
+	"io"
 	"math"
 	"sort"
 	"strings"
@@ -15,22 +17,7 @@ type FreeFloatingsContext struct {
 	freeFloatings          *[]FreeFloating
 	lastFreeFloatingUpdate time.Time
 	freeFloatingsMutex     sync.RWMutex
-	loadFreeFloatingData   bool
 	packageName            string
-	RefreshTime            time.Duration
-}
-
-func (d *FreeFloatingsContext) ManageFreeFloatingsStatus(activate bool) {
-	d.freeFloatingsMutex.Lock()
-	defer d.freeFloatingsMutex.Unlock()
-
-	d.loadFreeFloatingData = activate
-}
-
-func (d *FreeFloatingsContext) LoadFreeFloatingsData() bool {
-	d.freeFloatingsMutex.RLock()
-	defer d.freeFloatingsMutex.RUnlock()
-	return d.loadFreeFloatingData
 }
 
 func (d *FreeFloatingsContext) UpdateFreeFloating(freeFloatings []FreeFloating) {
@@ -48,10 +35,14 @@ func (d *FreeFloatingsContext) GetLastFreeFloatingsDataUpdate() time.Time {
 	return d.lastFreeFloatingUpdate
 }
 
-func (d *FreeFloatingsContext) GetRereshTime() string {
-	d.freeFloatingsMutex.Lock()
-	defer d.freeFloatingsMutex.Unlock()
-	return d.RefreshTime.String()
+func (d *FreeFloatingsContext) GetFreeFloatingsCount() int {
+	d.freeFloatingsMutex.RLock()
+	defer d.freeFloatingsMutex.RUnlock()
+
+	if d.freeFloatings == nil {
+		return 0
+	}
+	return len(*d.freeFloatings)
 }
 
 func (d *FreeFloatingsContext) GetPackageName() string {
@@ -122,3 +113,37 @@ type ByDistance []FreeFloating
 func (ff ByDistance) Len() int           { return len(ff) }
 func (ff ByDistance) Less(i, j int) bool { return ff[i].Distance < ff[j].Distance }
 func (ff ByDistance) Swap(i, j int)      { ff[i], ff[j] = ff[j], ff[i] }
+
+// freeFloatingsSnapshot is the content of the snapshot of the free-floatings
+type freeFloatingsSnapshot struct {
+	FreeFloatings []FreeFloating
+	LastUpdate    time.Time
+}
+
+// WriteSnapshot writes the free-floatings with the time of their last update
+func (d *FreeFloatingsContext) WriteSnapshot(w io.Writer) error {
+	d.freeFloatingsMutex.RLock()
+	defer d.freeFloatingsMutex.RUnlock()
+
+	if d.freeFloatings == nil {
+		return fmt.Errorf("no free-floatings to save")
+	}
+	return gob.NewEncoder(w).Encode(freeFloatingsSnapshot{
+		FreeFloatings: *d.freeFloatings,
+		LastUpdate:    d.lastFreeFloatingUpdate,
+	})
+}
+
+// ReadSnapshot replaces the free-floatings by the ones of a snapshot, with their time of update
+func (d *FreeFloatingsContext) ReadSnapshot(r io.Reader) error {
+	var snapshot freeFloatingsSnapshot
+	if err := gob.NewDecoder(r).Decode(&snapshot); err != nil {
+		return err
+	}
+
+	d.freeFloatingsMutex.Lock()
+	defer d.freeFloatingsMutex.Unlock()
+	d.freeFloatings = &snapshot.FreeFloatings
+	d.lastFreeFloatingUpdate = snapshot.LastUpdate
+	return nil
+}
@@ -4,11 +4,12 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
-	"reflect"
 	"time"
 
+	"github.com/CanalTP/forseti/internal/breaker"
 	"github.com/CanalTP/forseti/internal/connectors"
 	"github.com/CanalTP/forseti/internal/data"
 	"github.com/CanalTP/forseti/internal/freefloatings"
@@ -20,6 +21,15 @@ type FluctuoContext struct {
 	connector *connectors.Connector
 }
 
+func init() {
+	freefloatings.RegisterConnector(string(connectors.Connector_FLUCTUO),
+		func(uri url.URL, config freefloatings.Config) freefloatings.Connector {
+			var f = FluctuoContext{}
+			f.InitContext(uri, config.Refresh, config.Token, config.ConnectionTimeout)
+			return &f
+		})
+}
+
 func (d *FluctuoContext) InitContext(externalURI url.URL, loadExternalRefresh time.Duration, token string,
 	connectionTimeout time.Duration) {
 
@@ -35,63 +45,61 @@ func LoadFreeFloatingsData(data *data.Data) ([]freefloatings.FreeFloating, error
 	return freeFloatings, nil
 }
 
-func (d *FluctuoContext) RefreshFreeFloatingLoop(context *freefloatings.FreeFloatingsContext) {
-
-	context.SetPackageName(reflect.TypeOf(FluctuoContext{}).PkgPath())
-	context.RefreshTime = d.connector.GetRefreshTime()
-
-	// Wait 10 seconds before reloading external freefloating informations
-	time.Sleep(10 * time.Second)
-	for {
-		err := RefreshFreeFloatings(d, context)
-		if err != nil {
-			logrus.Error("Error while reloading freefloating data: ", err)
-		} else {
-			logrus.Debug("Free_floating data updated")
-		}
-		time.Sleep(d.connector.GetRefreshTime())
-	}
+// Load refreshes the free-floatings from Fluctuo
+func (d *FluctuoContext) Load(context *freefloatings.FreeFloatingsContext) error {
+	return RefreshFreeFloatings(d, context)
 }
 
 func RefreshFreeFloatings(fluctuoContext *FluctuoContext, context *freefloatings.FreeFloatingsContext) error {
-	// Continue using last loaded data if loading is deactivated
-	if !context.LoadFreeFloatingsData() {
-		return nil
-	}
 	begin := time.Now()
 	urlPath := fluctuoContext.connector.GetUrl()
-	resp, err := CallHttpClient(urlPath.String(), fluctuoContext.connector.GetToken())
+	var resp *http.Response
+	err := breaker.Call("fluctuo:"+urlPath.Host, func() (err error) {
+		resp, err = CallHttpClient(urlPath.String(), fluctuoContext.connector.GetToken())
+		if err != nil {
+			return err
+		}
+		return utils.CheckResponseStatus(resp)
+	})
 	if err != nil {
 		freefloatings.FreeFloatingsLoadingErrors.Inc()
 		return err
 	}
 
-	err = utils.CheckResponseStatus(resp)
+	freeFloatings, err := decodeFreeFloatings(resp.Body)
 	if err != nil {
 		freefloatings.FreeFloatingsLoadingErrors.Inc()
 		return err
 	}
 
-	data := &data.Data{}
-	decoder := json.NewDecoder(resp.Body)
-	err = decoder.Decode(data)
-	if err != nil {
-		freefloatings.FreeFloatingsLoadingErrors.Inc()
-		return err
-	}
+	context.UpdateFreeFloating(freeFloatings)
+	logrus.Debug("*** Size of data Fluctuo: ", len(freeFloatings))
+	freefloatings.FreeFloatingsLoadingDuration.Observe(time.Since(begin).Seconds())
+	return nil
+}
 
-	freeFloatings, err := LoadFreeFloatingsData(data)
+// LoadPayload loads the vehicles pushed in the format of the response of the API of Fluctuo
+func (d *FluctuoContext) LoadPayload(context *freefloatings.FreeFloatingsContext, r io.Reader) error {
+	begin := time.Now()
+	freeFloatings, err := decodeFreeFloatings(r)
 	if err != nil {
 		freefloatings.FreeFloatingsLoadingErrors.Inc()
 		return err
 	}
-
 	context.UpdateFreeFloating(freeFloatings)
-	logrus.Debug("*** Size of data Fluctuo: ", len(freeFloatings))
 	freefloatings.FreeFloatingsLoadingDuration.Observe(time.Since(begin).Seconds())
 	return nil
 }
 
+// decodeFreeFloatings reads the vehicles of a json response of the API of Fluctuo
+func decodeFreeFloatings(r io.Reader) ([]freefloatings.FreeFloating, error) {
+	data := &data.Data{}
+	if err := json.NewDecoder(r).Decode(data); err != nil {
+		return nil, err
+	}
+	return LoadFreeFloatingsData(data)
+}
+
 func CallHttpClient(siteHost, token string) (*http.Response, error) {
 	client := &http.Client{}
 	data := url.Values{}
@@ -7,6 +7,7 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/gin-gonic/gin"
@@ -313,3 +314,18 @@ func TestDataManagerGetFreeFloatings(t *testing.T) {
 	assert.Equal("0JT9J6", free_floatings[1].PublicId)
 	assert.Equal("Tier", free_floatings[1].ProviderName)
 }
+
+func TestLoadPayload(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	f, err := os.Open(fmt.Sprintf("%s/vehicles.json", fixtureDir))
+	require.Nil(err)
+	defer f.Close()
+	var fluctuoContext FluctuoContext
+	context := &freefloatings.FreeFloatingsContext{}
+	require.Nil(fluctuoContext.LoadPayload(context, f))
+	assert.NotZero(context.GetFreeFloatingsCount())
+
+	assert.Error(fluctuoContext.LoadPayload(context, strings.NewReader("{")))
+}
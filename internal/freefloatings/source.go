@@ -0,0 +1,265 @@
+package freefloatings
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+
+	"github.com/CanalTP/forseti/internal/sources"
+)
+
+const ModuleName = "free_floatings"
+
+func init() {
+	sources.Register(sources.Module{
+		Name: ModuleName,
+		Parameters: []sources.Parameter{
+			{Name: "uri", Flag: "free-floatings-uri", Default: "",
+				Usage: "format: [scheme:][//[userinfo@]host][/]path"},
+			{Name: "token", Flag: "free-floatings-token", Default: "",
+				Usage: "token for free floating source"},
+			{Name: "refresh-active", Flag: "free-floatings-refresh-active", Default: false,
+				Usage: "activate the periodic refresh of Fluctuo data"},
+			{Name: "refresh", Flag: "free-floatings-refresh", Default: 30 * time.Second,
+				Usage: "time between refresh of vehicles in Fluctuo data"},
+			{Name: "connector", Flag: "free-floatings-type", Default: "fluctuo",
+				Usage: "connector type to load data source"},
+			{Name: "username", Flag: "free-floatings-username", Default: "",
+				Usage: "username for getting API access tokens"},
+			{Name: "password", Flag: "free-floatings-password", Default: "",
+				Usage: "password for getting API access tokens"},
+			{Name: "providers", Flag: "free-floatings-providers", Default: "",
+				Usage: "list of providers to get data \nexample: 19,127,392"},
+			sources.ConnectionTimeout,
+			sources.BackoffInitial,
+			sources.BackoffMax,
+			sources.BackoffJitter,
+			sources.SnapshotDir,
+			sources.SnapshotInterval,
+		},
+		New: NewSourceFromConfig,
+	})
+}
+
+type Config struct {
+	URI               string        `mapstructure:"uri"`
+	Token             string        `mapstructure:"token"`
+	RefreshActive     bool          `mapstructure:"refresh-active"`
+	Refresh           time.Duration `mapstructure:"refresh"`
+	Connector         string        `mapstructure:"connector"`
+	UserName          string        `mapstructure:"username"`
+	Password          string        `mapstructure:"password"`
+	Providers         []string      `mapstructure:"providers"`
+	ConnectionTimeout time.Duration `mapstructure:"connection-timeout"`
+	sources.Backoff   `mapstructure:",squash"`
+	sources.Snapshot  `mapstructure:",squash"`
+}
+
+// Connector loads the free-floatings from an external provider into the context
+type Connector interface {
+	Load(context *FreeFloatingsContext) error
+}
+
+// PayloadLoader is implemented by the connectors whose provider can push its data, in the format of its API
+type PayloadLoader interface {
+	LoadPayload(context *FreeFloatingsContext, r io.Reader) error
+}
+
+// ConnectorFactory creates a connector to the provider located at uri
+type ConnectorFactory func(uri url.URL, config Config) Connector
+
+var connectorFactories = make(map[string]ConnectorFactory)
+
+// RegisterConnector makes a free-floating provider available with the connector type name
+func RegisterConnector(name string, factory ConnectorFactory) {
+	if _, ok := connectorFactories[name]; ok {
+		panic(fmt.Sprintf("free-floating connector %s is already registered", name))
+	}
+	connectorFactories[name] = factory
+}
+
+// FreeFloatingsSource serves the free-floatings loaded periodically from a provider
+type FreeFloatingsSource struct {
+	*sources.Refresher
+	instance  string
+	context   *FreeFloatingsContext
+	connector Connector
+	config    Config
+	mutex     sync.RWMutex
+}
+
+// NewFreeFloatingsSource creates the source with a deactivated refresh, the first load is done 10 seconds
+// after the start
+func NewFreeFloatingsSource(context *FreeFloatingsContext, connector Connector) *FreeFloatingsSource {
+	source := &FreeFloatingsSource{
+		context: context,
+	}
+	source.Refresher = sources.NewRefresher(source, 0, 10*time.Second, false)
+	source.setConnector(connector)
+	return source
+}
+
+func NewSourceFromConfig(instance string, config *viper.Viper) (sources.Source, error) {
+	var c Config
+	if err := config.Unmarshal(&c); err != nil {
+		return nil, err
+	}
+	if len(c.URI) == 0 || c.Refresh.Seconds() <= 0 {
+		return nil, nil
+	}
+	uri, err := url.Parse(c.URI)
+	if err != nil {
+		return nil, err
+	}
+	factory, ok := connectorFactories[c.Connector]
+	if !ok {
+		return nil, fmt.Errorf("wrong free-floating connector type passed: %s", c.Connector)
+	}
+
+	source := NewFreeFloatingsSource(&FreeFloatingsContext{}, factory(*uri, c))
+	source.SetRefresh(c.Refresh)
+	source.ManageRefreshStatus(c.RefreshActive)
+	source.SetBackoff(c.Backoff)
+	source.SetSnapshot(c.Snapshot)
+	source.instance = instance
+	source.config = c
+	return source, nil
+}
+
+// setConnector changes the provider of the source, the name of its package is given as source in the status
+func (s *FreeFloatingsSource) setConnector(connector Connector) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.connector = connector
+	if connector != nil {
+		t := reflect.TypeOf(connector)
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		s.context.SetPackageName(t.PkgPath())
+	}
+}
+
+func (s *FreeFloatingsSource) GetContext() *FreeFloatingsContext {
+	return s.context
+}
+
+/********* INTERFACE METHODS IMPLEMENTS *********/
+
+func (s *FreeFloatingsSource) Name() string {
+	return ModuleName
+}
+
+func (s *FreeFloatingsSource) Instance() string {
+	return s.instance
+}
+
+func (s *FreeFloatingsSource) Start() {
+	s.mutex.RLock()
+	disabled := s.connector == nil || s.GetRefresh().Seconds() <= 0
+	s.mutex.RUnlock()
+	if disabled {
+		return
+	}
+	s.Refresher.Start()
+}
+
+func (s *FreeFloatingsSource) Load() error {
+	s.mutex.RLock()
+	connector := s.connector
+	s.mutex.RUnlock()
+	if connector == nil {
+		return fmt.Errorf("no free-floating connector")
+	}
+	return connector.Load(s.context)
+}
+
+// LoadPayload loads the free-floatings pushed by the provider, if its connector accepts pushed data
+func (s *FreeFloatingsSource) LoadPayload(r io.Reader) error {
+	s.mutex.RLock()
+	payloadLoader, ok := s.connector.(PayloadLoader)
+	s.mutex.RUnlock()
+	if !ok {
+		return sources.ErrNoIngest
+	}
+	return payloadLoader.LoadPayload(s.context, r)
+}
+
+// Reload restarts the refresh with a connector created from the new configuration, the loaded
+// free-floatings are kept. The refresh is stopped if the uri is removed from the configuration.
+func (s *FreeFloatingsSource) Reload(config *viper.Viper) error {
+	var c Config
+	if err := config.Unmarshal(&c); err != nil {
+		return err
+	}
+	s.SetBackoff(c.Backoff)
+	s.SetSnapshot(c.Snapshot)
+	s.mutex.RLock()
+	unchanged := reflect.DeepEqual(c, s.config)
+	s.mutex.RUnlock()
+	if unchanged && s.Running() {
+		return nil
+	}
+
+	var connector Connector
+	if len(c.URI) > 0 && c.Refresh.Seconds() > 0 {
+		uri, err := url.Parse(c.URI)
+		if err != nil {
+			return err
+		}
+		factory, ok := connectorFactories[c.Connector]
+		if !ok {
+			return fmt.Errorf("wrong free-floating connector type passed: %s", c.Connector)
+		}
+		connector = factory(*uri, c)
+	}
+
+	s.Stop()
+	s.setConnector(connector)
+	s.mutex.Lock()
+	s.config = c
+	s.mutex.Unlock()
+	s.SetRefresh(c.Refresh)
+	s.ManageRefreshStatus(c.RefreshActive)
+	s.Start()
+	return nil
+}
+
+func (s *FreeFloatingsSource) AddEntryPoints(r *gin.Engine) {
+	AddFreeFloatingsEntryPoint(r, s.context)
+}
+
+func (s *FreeFloatingsSource) GetStatus() sources.Status {
+	status := s.Refresher.Status()
+	status.Source = s.context.GetPackageName()
+	status.LastUpdate = s.context.GetLastFreeFloatingsDataUpdate()
+	return status
+}
+
+func (s *FreeFloatingsSource) LoadedItems() int {
+	return s.context.GetFreeFloatingsCount()
+}
+
+func (s *FreeFloatingsSource) URI() url.URL {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	uri, err := url.Parse(s.config.URI)
+	if err != nil {
+		return url.URL{}
+	}
+	return *uri
+}
+
+func (s *FreeFloatingsSource) WriteSnapshot(w io.Writer) error {
+	return s.context.WriteSnapshot(w)
+}
+
+func (s *FreeFloatingsSource) ReadSnapshot(r io.Reader) error {
+	return s.context.ReadSnapshot(r)
+}
@@ -3,9 +3,11 @@ package gtfsrtvehiclepositions
 import (
 	"fmt"
 	"io/ioutil"
+	"net/http"
 	"strconv"
 
 	"github.com/CanalTP/forseti/google_transit"
+	"github.com/CanalTP/forseti/internal/breaker"
 	"github.com/CanalTP/forseti/internal/connectors"
 	"github.com/CanalTP/forseti/internal/utils"
 	"github.com/sirupsen/logrus"
@@ -44,8 +46,13 @@ func NewGtfsRt(timestamp string, v []VehicleGtfsRt) *GtfsRt {
 }
 
 func LoadGtfsRt(connector *connectors.Connector) (*GtfsRt, error) {
-	resp, err := utils.GetHttpClient_(connector.GetUrl(), connector.GetToken(), "Authorization",
-		connector.GetConnectionTimeout())
+	var resp *http.Response
+	uri := connector.GetUrl()
+	err := breaker.Call("gtfs-rt:"+uri.Host, func() (err error) {
+		resp, err = utils.GetHttpClient_(uri, connector.GetToken(), "Authorization",
+			connector.GetConnectionTimeout())
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -1,70 +1,75 @@
 package manager
 
 import (
-	"github.com/CanalTP/forseti/internal/departures"
-	"github.com/CanalTP/forseti/internal/equipments"
-	"github.com/CanalTP/forseti/internal/freefloatings"
-	"github.com/CanalTP/forseti/internal/parkings"
-	vehicleoccupanciesv2 "github.com/CanalTP/forseti/internal/vehicleoccupancies_v2"
-	"github.com/CanalTP/forseti/internal/vehiclepositions"
+	"context"
+	"sync"
+
+	"github.com/CanalTP/forseti/internal/sources"
 )
 
 // Data manager for all apis
 type DataManager struct {
-	freeFloatingsContext       *freefloatings.FreeFloatingsContext
-	vehiculeOccupanciesContext vehicleoccupanciesv2.IVehicleOccupancy
-	equipmentsContext          *equipments.EquipmentsContext
-	departuresContext          *departures.DeparturesContext
-	parkingsContext            *parkings.ParkingsContext
-	vehiclePositionsContext    vehiclepositions.IConnectors
-}
-
-func (d *DataManager) SetEquipmentsContext(equipmentsContext *equipments.EquipmentsContext) {
-	d.equipmentsContext = equipmentsContext
-}
-
-func (d *DataManager) GetEquipmentsContext() *equipments.EquipmentsContext {
-	return d.equipmentsContext
-}
-
-func (d *DataManager) SetFreeFloatingsContext(freeFloatingsContext *freefloatings.FreeFloatingsContext) {
-	d.freeFloatingsContext = freeFloatingsContext
+	sources []sources.Source
+	mutex   sync.RWMutex
 }
 
-func (d *DataManager) GetFreeFloatingsContext() *freefloatings.FreeFloatingsContext {
-	return d.freeFloatingsContext
+func (d *DataManager) AddSource(source sources.Source) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.sources = append(d.sources, source)
 }
 
-func (d *DataManager) SetDeparturesContext(departuresContext *departures.DeparturesContext) {
-	d.departuresContext = departuresContext
+// RemoveSource removes the source from the manager, it is no longer reported by the status and the probes
+func (d *DataManager) RemoveSource(source sources.Source) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	for i, s := range d.sources {
+		if s == source {
+			d.sources = append(d.sources[:i:i], d.sources[i+1:]...)
+			return
+		}
+	}
 }
 
-func (d *DataManager) GetDeparturesContext() *departures.DeparturesContext {
-	return d.departuresContext
+func (d *DataManager) GetSources() []sources.Source {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	return append([]sources.Source(nil), d.sources...)
 }
 
-func (d *DataManager) SetParkingsContext(parkingsContext *parkings.ParkingsContext) {
-	d.parkingsContext = parkingsContext
+// GetSource returns the source with the given key (module or module:instance), nil if there is none
+func (d *DataManager) GetSource(key string) sources.Source {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	for _, s := range d.sources {
+		if sources.Key(s) == key {
+			return s
+		}
+	}
+	return nil
 }
 
-func (d *DataManager) GetParkingsContext() *parkings.ParkingsContext {
-	return d.parkingsContext
-}
-
-func (d *DataManager) SetVehicleOccupanciesContext(
-	vehiculeOccupanciesContext vehicleoccupanciesv2.IVehicleOccupancy) {
-	d.vehiculeOccupanciesContext = vehiculeOccupanciesContext
-}
-
-func (d *DataManager) GetVehicleOccupanciesContext() vehicleoccupanciesv2.IVehicleOccupancy {
-	return d.vehiculeOccupanciesContext
-}
-
-func (d *DataManager) SetVehiclePositionsContext(
-	vehiclePositionsContext vehiclepositions.IConnectors) {
-	d.vehiclePositionsContext = vehiclePositionsContext
-}
+// Stop ends the refresh of every source and waits for the current refreshes to finish,
+// it returns an error if they are not finished before the end of the context
+func (d *DataManager) Stop(ctx context.Context) error {
+	var wg sync.WaitGroup
+	for _, s := range d.GetSources() {
+		wg.Add(1)
+		go func(s sources.Source) {
+			defer wg.Done()
+			s.Stop()
+		}(s)
+	}
 
-func (d *DataManager) GetVehiclePositionsContext() vehiclepositions.IConnectors {
-	return d.vehiclePositionsContext
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
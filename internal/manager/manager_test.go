@@ -0,0 +1,66 @@
+package manager
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/CanalTP/forseti/internal/sources"
+)
+
+type slowSource struct {
+	name  string
+	delay time.Duration
+	loop  sources.Loop
+}
+
+func (s *slowSource) Name() string                 { return s.name }
+func (s *slowSource) Instance() string             { return "" }
+func (s *slowSource) AddEntryPoints(r *gin.Engine) {}
+func (s *slowSource) GetStatus() sources.Status {
+	return sources.Status{RefreshActive: s.loop.Running()}
+}
+func (s *slowSource) Reload(config *viper.Viper) error { return nil }
+func (s *slowSource) Stop()                            { s.loop.Stop() }
+func (s *slowSource) Start() {
+	s.loop.Start(func(ctx context.Context) {
+		<-ctx.Done()
+		// the current refresh ends after the cancellation
+		time.Sleep(s.delay)
+	})
+}
+
+func TestStop(t *testing.T) {
+	assert := assert.New(t)
+
+	manager := &DataManager{}
+	fast := &slowSource{name: "fast", delay: 0}
+	slow := &slowSource{name: "slow", delay: 10 * time.Millisecond}
+	manager.AddSource(fast)
+	manager.AddSource(slow)
+	assert.Equal(slow, manager.GetSource("slow"))
+	assert.Nil(manager.GetSource("other"))
+
+	other := &slowSource{name: "other"}
+	manager.AddSource(other)
+	manager.RemoveSource(other)
+	assert.Nil(manager.GetSource("other"))
+	assert.Equal([]sources.Source{fast, slow}, manager.GetSources())
+
+	fast.Start()
+	slow.Start()
+	assert.Nil(manager.Stop(context.Background()))
+	assert.False(fast.GetStatus().RefreshActive)
+	assert.False(slow.GetStatus().RefreshActive)
+
+	// the refreshes which do not end before the timeout are abandoned
+	slow.delay = time.Second
+	slow.Start()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(context.DeadlineExceeded, manager.Stop(ctx))
+}
@@ -2,14 +2,18 @@ package parkings
 
 import (
 	"fmt"
+	"io"
+	"net/url"
 	"sync"
 	"time"
+
+	"github.com/CanalTP/forseti/internal/utils"
 )
 
 type ParkingsContext struct {
-	parkings          *map[string]Parking
-	lastParkingUpdate time.Time
-	parkingsMutex     sync.RWMutex
+	utils.FileState
+	parkings      *map[string]Parking
+	parkingsMutex sync.RWMutex
 }
 
 func (d *ParkingsContext) UpdateParkings(parkings map[string]Parking) {
@@ -17,14 +21,21 @@ func (d *ParkingsContext) UpdateParkings(parkings map[string]Parking) {
 	defer d.parkingsMutex.Unlock()
 
 	d.parkings = &parkings
-	d.lastParkingUpdate = time.Now()
+	d.MarkUpdated()
 }
 
 func (d *ParkingsContext) GetLastParkingsDataUpdate() time.Time {
+	return d.GetLastUpdate()
+}
+
+func (d *ParkingsContext) GetParkingsCount() int {
 	d.parkingsMutex.RLock()
 	defer d.parkingsMutex.RUnlock()
 
-	return d.lastParkingUpdate
+	if d.parkings == nil {
+		return 0
+	}
+	return len(*d.parkings)
 }
 
 func (d *ParkingsContext) GetParkingsByIds(ids []string) (parkings []Parking, errors []error) {
@@ -82,3 +93,37 @@ func (d *ParkingsContext) GetParkingById(id string) (p Parking, e error) {
 
 	return p, e
 }
+
+// RefreshFile loads the file of the parkings if it has been modified since its last load
+func (d *ParkingsContext) RefreshFile(uri url.URL, connectionTimeout time.Duration) error {
+	return RefreshParkings(d, uri, connectionTimeout)
+}
+
+// LoadPayload loads the parkings pushed by their provider
+func (d *ParkingsContext) LoadPayload(r io.Reader) error {
+	return IngestParkings(d, r)
+}
+
+// WriteSnapshot writes the parkings with the time of their last update
+func (d *ParkingsContext) WriteSnapshot(w io.Writer) error {
+	d.parkingsMutex.RLock()
+	defer d.parkingsMutex.RUnlock()
+
+	if d.parkings == nil {
+		return fmt.Errorf("no parkings to save")
+	}
+	return d.FileState.WriteSnapshot(w, *d.parkings)
+}
+
+// ReadSnapshot replaces the parkings by the ones of a snapshot, with their time of update
+func (d *ParkingsContext) ReadSnapshot(r io.Reader) error {
+	var parkings map[string]Parking
+	if err := d.FileState.ReadSnapshot(r, &parkings); err != nil {
+		return err
+	}
+
+	d.parkingsMutex.Lock()
+	defer d.parkingsMutex.Unlock()
+	d.parkings = &parkings
+	return nil
+}
@@ -1,53 +1,84 @@
 package parkings
 
 import (
+	"io"
 	"net/url"
 	"time"
 
-	"github.com/CanalTP/forseti/internal/utils"
 	"github.com/sirupsen/logrus"
+
+	"github.com/CanalTP/forseti/internal/utils"
 )
 
-func RefreshParkingsLoop(context *ParkingsContext,
-	parkingsURI url.URL,
-	parkingsRefresh, connectionTimeout time.Duration) {
-	if len(parkingsURI.String()) == 0 || parkingsRefresh.Seconds() <= 0 {
-		logrus.Debug("Parking data refreshing is disabled")
-		return
-	}
-	for {
-		err := RefreshParkings(context, parkingsURI, connectionTimeout)
-		if err != nil {
-			logrus.Error("Error while reloading parking data: ", err)
-		} else {
-			logrus.Debug("Parking data updated")
-		}
-		time.Sleep(parkingsRefresh)
-	}
+// layout is the default layout of the file of the parkings, with a header
+var layout = utils.Layout{
+	Fields: []string{"id", "label", "updated_time", "", "available_standard_spaces", "total_standard_spaces",
+		"available_accessible_spaces", "total_accessible_spaces"},
+	DateFields: []string{"updated_time"},
+}
+
+var defaultLoadDataOptions = utils.LoadDataOptions{
+	Delimiter:     ';',
+	NbFields:      0,    // We might not have etereogenous lines
+	SkipFirstLine: true, // First line is a header
 }
 
 func RefreshParkings(context *ParkingsContext, uri url.URL, connectionTimeout time.Duration) error {
 	begin := time.Now()
-	file, err := utils.GetFile(uri, connectionTimeout)
+	file, err := utils.GetFileIfModified(uri, connectionTimeout, context.GetFileVersion())
+	if err == utils.ErrNotModified {
+		context.MarkUnchanged()
+		ParkingsSkippedReloads.Inc()
+		return nil
+	}
 	if err != nil {
 		ParkingsLoadingErrors.Inc()
 		return err
 	}
+	defer file.Close()
 
 	parkingsConsumer := makeParkingLineConsumer()
-	loadDataOptions := utils.LoadDataOptions{
-		Delimiter:     ';',
-		NbFields:      0,    // We might not have etereogenous lines
-		SkipFirstLine: true, // First line is a header
-	}
-	err = utils.LoadDataWithOptions(file, parkingsConsumer, loadDataOptions)
+	options := context.GetLoadDataOptions(defaultLoadDataOptions)
+	report, err := utils.LoadDataWithReport(file, parkingsConsumer, options)
+	context.SetLoadReport(report)
+	ParkingsSkippedRecords.Add(float64(report.Skipped))
 	if err != nil {
 		ParkingsLoadingErrors.Inc()
 		return err
 	}
 
+	if err = file.Done(); err != nil {
+		logrus.Errorf("Impossible to archive or delete the file of the parkings: %s", err)
+	}
+	version := file.Version()
+	if version.Checksum == context.GetFileVersion().Checksum {
+		// the file has been written again with the same content
+		context.MarkUnchanged()
+		context.SetFileVersion(version)
+		ParkingsSkippedReloads.Inc()
+		return nil
+	}
 	context.UpdateParkings(parkingsConsumer.parkings)
+	context.SetFileVersion(version)
 	ParkingsLoadingDuration.Observe(time.Since(begin).Seconds())
 
 	return nil
 }
+
+// IngestParkings loads the parkings pushed by their provider, in the format of the file of the parkings
+func IngestParkings(context *ParkingsContext, payload io.Reader) error {
+	begin := time.Now()
+	parkingsConsumer := makeParkingLineConsumer()
+	options := context.GetLoadDataOptions(defaultLoadDataOptions)
+	report, err := utils.LoadDataWithReport(payload, parkingsConsumer, options)
+	context.SetLoadReport(report)
+	ParkingsSkippedRecords.Add(float64(report.Skipped))
+	if err != nil {
+		ParkingsLoadingErrors.Inc()
+		return err
+	}
+	context.UpdateParkings(parkingsConsumer.parkings)
+	context.MarkPushed()
+	ParkingsLoadingDuration.Observe(time.Since(begin).Seconds())
+	return nil
+}
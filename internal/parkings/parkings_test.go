@@ -4,15 +4,20 @@ import (
 	"encoding/json"
 
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"os"
+	"path/filepath"
 	"sort"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -319,3 +324,114 @@ func TestGetParking(t *testing.T) {
 	assert.Equal("Loulou", parkings[1].ID)
 	assert.Equal("Riri", parkings[2].ID)
 }
+
+func TestReloadParkingsSource(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	config := viper.New()
+	config.Set("uri", fmt.Sprintf("file://%s/parkings.txt", fixtureDir))
+	config.Set("refresh", time.Minute)
+	s, err := NewSourceFromConfig("", config)
+	require.Nil(err)
+	source := s.(*ParkingsSource)
+
+	source.Start()
+	defer source.Stop()
+	assert.Eventually(func() bool {
+		parkings, _ := source.GetContext().GetParkings()
+		return len(parkings) > 0
+	}, time.Second, 10*time.Millisecond)
+	assert.True(source.GetStatus().RefreshActive)
+
+	// the new refresh is applied, the parkings are kept
+	config.Set("refresh", 2*time.Minute)
+	require.Nil(source.Reload(config))
+	assert.True(source.GetStatus().RefreshActive)
+	assert.Equal("2m0s", source.GetStatus().RefreshTime)
+
+	// removing the uri stops the refresh
+	config.Set("uri", "")
+	require.Nil(source.Reload(config))
+	assert.False(source.GetStatus().RefreshActive)
+	parkings, err := source.GetContext().GetParkings()
+	require.Nil(err)
+	assert.NotEmpty(parkings)
+}
+
+func TestRefreshMappedParkings(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	config := viper.New()
+	config.Set("uri", fmt.Sprintf("file://%s/parkings_mapped.txt", fixtureDir))
+	config.Set("refresh", time.Minute)
+	columns := "available_accessible_spaces=DISPO_PMR,total_standard_spaces=CAPACITE,total_accessible_spaces=6"
+	config.Set("csv-columns", "available_standard_spaces=3,"+columns)
+	config.Set("csv-date-format", "02/01/2006 15:04")
+	config.Set("csv-delimiter", "tab")
+	s, err := NewSourceFromConfig("", config)
+	require.Nil(err)
+	source := s.(*ParkingsSource)
+	require.Nil(source.Load())
+
+	location, err := time.LoadLocation("Europe/Paris")
+	require.Nil(err)
+	p, err := source.GetContext().GetParkingById("DECC")
+	require.Nil(err)
+	assert.Equal("Décines Centre", p.Label)
+	assert.Equal(time.Date(2018, 9, 17, 19, 29, 0, 0, location), p.UpdatedTime)
+	assert.Equal(82, p.AvailableStandardSpaces)
+	assert.Equal(105, p.TotalStandardSpaces)
+	assert.Equal(3, p.AvailableAccessibleSpaces)
+	assert.Equal(0, p.TotalAccessibleSpaces)
+	assert.Equal(2, source.LoadedItems())
+
+	// a new layout is applied at the next refresh, even if the file has not changed
+	config.Set("csv-columns", "available_standard_spaces=CAPACITE,"+columns)
+	require.Nil(source.Reload(config))
+	require.Nil(source.Load())
+	p, err = source.GetContext().GetParkingById("DECC")
+	require.Nil(err)
+	assert.Equal(105, p.AvailableStandardSpaces)
+}
+
+func TestRefreshParkingsCountsSkippedRecords(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	content := "COD;LIB;DATE_COMPTAGE;DATE_DIFFUSION;DISPO;CAP;PMR_DISPO;PMR_CAP\n" +
+		"DECC;Décines Centre;2018-09-17 19:29:00;2018-09-17 19:30:02;82;105;0;3\n" +
+		"VAI1;Vaise 1;this_should_be_a_date;2018-09-17 19:30:02;256;497;0;10\n"
+	dir, err := ioutil.TempDir("", "parkings")
+	require.Nil(err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "parkings.txt")
+	require.Nil(ioutil.WriteFile(path, []byte(content), 0600))
+	uri, err := url.Parse("file://" + path)
+	require.Nil(err)
+
+	parkingsContext := &ParkingsContext{}
+	options := defaultLoadDataOptions
+	options.MaxErrorRatio = 0.1
+	parkingsContext.SetLoadDataOptions(options)
+	skipped := skippedRecords(t)
+
+	// the invalid records of a rejected file or push are counted
+	assert.Error(RefreshParkings(parkingsContext, *uri, time.Second))
+	assert.Equal(skipped+1, skippedRecords(t))
+	assert.Error(IngestParkings(parkingsContext, strings.NewReader(content)))
+	assert.Equal(skipped+2, skippedRecords(t))
+
+	options.MaxErrorRatio = 0.5
+	parkingsContext.SetLoadDataOptions(options)
+	require.Nil(RefreshParkings(parkingsContext, *uri, time.Second))
+	assert.Equal(skipped+3, skippedRecords(t))
+	assert.Equal(1, parkingsContext.GetParkingsCount())
+}
+
+func skippedRecords(t *testing.T) float64 {
+	var metric dto.Metric
+	require.Nil(t, ParkingsSkippedRecords.Write(&metric))
+	return metric.GetCounter().GetValue()
+}
@@ -19,4 +19,18 @@ var (
 		Name:      "loading_errors",
 		Help:      "current number of http request being served",
 	})
+
+	ParkingsSkippedReloads = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "forseti",
+		Subsystem: "parkings",
+		Name:      "skipped_reloads",
+		Help:      "number of refreshes skipped because the file has not changed",
+	})
+
+	ParkingsSkippedRecords = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "forseti",
+		Subsystem: "parkings",
+		Name:      "skipped_records",
+		Help:      "number of invalid records skipped in the loaded files",
+	})
 )
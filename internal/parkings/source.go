@@ -0,0 +1,62 @@
+package parkings
+
+import (
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+
+	"github.com/CanalTP/forseti/internal/sources"
+)
+
+const ModuleName = "parkings"
+
+var module = sources.FileModule{
+	Name:           ModuleName,
+	Data:           "parkings",
+	Layout:         &layout,
+	Options:        defaultLoadDataOptions,
+	ColumnsExample: "id=ID,updated_time=DATE,available_standard_spaces=3",
+}
+
+func init() {
+	sources.Register(sources.Module{
+		Name:       ModuleName,
+		Parameters: module.Parameters(),
+		New:        NewSourceFromConfig,
+	})
+}
+
+// ParkingsSource serves the P+R parkings read periodically from a file
+type ParkingsSource struct {
+	*sources.FileSource
+	context *ParkingsContext
+}
+
+func NewParkingsSource(context *ParkingsContext, uri url.URL,
+	refresh, connectionTimeout time.Duration) *ParkingsSource {
+	source := &ParkingsSource{context: context}
+	source.FileSource = sources.NewFileSource(source, module, context, uri, refresh, connectionTimeout)
+	return source
+}
+
+func NewSourceFromConfig(instance string, config *viper.Viper) (sources.Source, error) {
+	source := NewParkingsSource(&ParkingsContext{}, url.URL{}, 0, 0)
+	if configured, err := source.Configure(instance, config); !configured {
+		return nil, err
+	}
+	return source, nil
+}
+
+func (s *ParkingsSource) GetContext() *ParkingsContext {
+	return s.context
+}
+
+func (s *ParkingsSource) AddEntryPoints(r *gin.Engine) {
+	AddParkingsEntryPoint(r, s.context)
+}
+
+func (s *ParkingsSource) LoadedItems() int {
+	return s.context.GetParkingsCount()
+}
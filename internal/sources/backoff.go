@@ -0,0 +1,72 @@
+package sources
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Parameters of the backoff of the refresh, shared by every module
+var (
+	BackoffInitial = Parameter{"backoff-initial", "backoff-initial", 10 * time.Second,
+		"time before retrying a failed refresh, the refresh period if shorter"}
+	BackoffMax = Parameter{"backoff-max", "backoff-max", 30 * time.Minute,
+		"maximum time between two refreshes after consecutive failures, the time is doubled on each failure " +
+			"(0 to disable the backoff)"}
+	BackoffJitter = Parameter{"backoff-jitter", "backoff-jitter", 0.2,
+		"random part of the time between two refreshes after a failure (0.2: +/- 20%)"}
+)
+
+// Backoff configures the wait before retrying a failed refresh, it is embedded with the tag
+// `mapstructure:",squash"` in the configuration of the modules
+type Backoff struct {
+	Initial time.Duration `mapstructure:"backoff-initial"`
+	Max     time.Duration `mapstructure:"backoff-max"`
+	Jitter  float64       `mapstructure:"backoff-jitter"`
+}
+
+// maxShift bounds the exponent of the backoff to avoid the overflow of the duration
+const maxShift = 16
+
+// Wait returns the time to wait after failures consecutive failures: the first retry waits Initial (or the refresh
+// period if shorter), the wait is doubled on each following failure up to Max, plus or minus a random part
+// without exceeding Max. random is a number in [0,1).
+func (b Backoff) Wait(refresh time.Duration, failures int, random float64) time.Duration {
+	if failures == 0 || b.Max <= 0 {
+		return refresh
+	}
+	base := refresh
+	if b.Initial > 0 && b.Initial < base {
+		base = b.Initial
+	}
+	shift := failures - 1
+	if shift > maxShift {
+		shift = maxShift
+	}
+	wait := base << uint(shift)
+	if wait > b.Max || wait <= 0 {
+		wait = b.Max
+	}
+	wait += time.Duration(b.Jitter * (2*random - 1) * float64(wait))
+	if wait > b.Max {
+		wait = b.Max
+	}
+	return wait
+}
+
+// SetBackoff changes the backoff applied after a failed refresh
+func (r *Refresher) SetBackoff(backoff Backoff) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.backoff = backoff
+}
+
+// nextWait returns the function giving the time to wait before the next refresh, according to the current
+// refresh period and to the number of consecutive failures
+func (r *Refresher) nextWait() func() time.Duration {
+	random := rand.Float64() // nolint: gosec
+	return func() time.Duration {
+		r.mutex.RLock()
+		defer r.mutex.RUnlock()
+		return r.backoff.Wait(r.refresh, r.failures, random)
+	}
+}
@@ -0,0 +1,90 @@
+package sources
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackoffWait(t *testing.T) {
+	assert := assert.New(t)
+
+	backoff := Backoff{Initial: 10 * time.Second, Max: 10 * time.Minute}
+	assert.Equal(time.Minute, backoff.Wait(time.Minute, 0, 0.5))
+	// the first retry is sooner than the next refresh
+	assert.Equal(10*time.Second, backoff.Wait(time.Minute, 1, 0.5))
+	assert.Equal(20*time.Second, backoff.Wait(time.Minute, 2, 0.5))
+	assert.Equal(80*time.Second, backoff.Wait(time.Minute, 4, 0.5))
+	assert.Equal(10*time.Minute, backoff.Wait(time.Minute, 7, 0.5))
+	assert.Equal(10*time.Minute, backoff.Wait(time.Minute, 1000, 0.5))
+	// the retry of a short refresh period waits the period
+	assert.Equal(5*time.Second, backoff.Wait(5*time.Second, 1, 0.5))
+	assert.Equal(10*time.Second, backoff.Wait(5*time.Second, 2, 0.5))
+
+	// a refresh period above the maximum
+	assert.Equal(10*time.Second, backoff.Wait(time.Hour, 1, 0.5))
+	assert.Equal(10*time.Minute, backoff.Wait(time.Hour, 10, 0.5))
+	assert.Equal(time.Minute, Backoff{Max: time.Minute}.Wait(time.Hour, 1, 0.5))
+
+	// disabled backoff
+	assert.Equal(time.Minute, Backoff{}.Wait(time.Minute, 3, 0.5))
+	assert.Equal(time.Minute, Backoff{Initial: time.Second}.Wait(time.Minute, 3, 0.5))
+
+	// jitter, without exceeding the maximum
+	backoff.Jitter = 0.2
+	assert.Equal(time.Minute, backoff.Wait(time.Minute, 0, 0))
+	assert.Equal(8*time.Second, backoff.Wait(time.Minute, 1, 0))
+	assert.Equal(12*time.Second, backoff.Wait(time.Minute, 1, 1))
+	assert.Equal(8*time.Minute, backoff.Wait(time.Minute, 1000, 0))
+	assert.Equal(10*time.Minute, backoff.Wait(time.Minute, 1000, 1))
+}
+
+func TestBackoffConfig(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	flags := pflag.NewFlagSet("forseti", pflag.ContinueOnError)
+	addFlag(flags, BackoffMax)
+	addFlag(flags, BackoffJitter)
+	require.Nil(flags.Parse([]string{"--backoff-jitter=0.5"}))
+	global := viper.New()
+	require.Nil(global.BindPFlags(flags))
+	global.Set("parkings-tram-backoff-max", "1h")
+
+	module := Module{Name: "parkings", Parameters: []Parameter{BackoffMax, BackoffJitter}}
+	var c struct {
+		Backoff `mapstructure:",squash"`
+	}
+	require.Nil(module.config(global, "tram").Unmarshal(&c))
+	assert.Equal(Backoff{Max: time.Hour, Jitter: 0.5}, c.Backoff)
+
+	assert.Nil(checkValue(BackoffJitter, 0.1))
+	assert.Nil(checkValue(BackoffJitter, "0.1"))
+	assert.Error(checkValue(BackoffJitter, "a lot"))
+	assert.Error(checkValue(BackoffJitter, fmt.Sprint))
+}
+
+func TestRefresherBackoff(t *testing.T) {
+	assert := assert.New(t)
+
+	loader := newFakeLoader(time.Minute, true)
+	loader.SetBackoff(Backoff{Initial: 10 * time.Second, Max: time.Hour})
+	wait := loader.nextWait()
+	assert.Equal(time.Minute, wait())
+
+	loader.err = fmt.Errorf("unreachable")
+	assert.Error(loader.Refresh())
+	assert.Equal(10*time.Second, wait())
+	assert.Error(loader.Refresh())
+	assert.Equal(20*time.Second, wait())
+
+	// the backoff ends with the first successful refresh
+	loader.err = nil
+	assert.Nil(loader.Refresh())
+	assert.Equal(time.Minute, wait())
+}
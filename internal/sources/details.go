@@ -0,0 +1,77 @@
+package sources
+
+import (
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/CanalTP/forseti/internal/utils"
+)
+
+// HistorySize is the number of refreshes kept in the history of a source
+const HistorySize = 10
+
+// Outcome is the result of a refresh of the data of a source
+type Outcome struct {
+	Time     time.Time `json:"time"`
+	Duration string    `json:"duration"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// Details is the detailed status of a source
+type Details struct {
+	Status
+	LastSuccess         time.Time         `json:"last_success"`
+	LastAttempt         time.Time         `json:"last_attempt"`
+	LastError           string            `json:"last_error,omitempty"`
+	ConsecutiveFailures int               `json:"consecutive_failures"`
+	LoadedItems         int               `json:"loaded_items"`
+	URI                 string            `json:"uri,omitempty"`
+	LastLoad            *utils.LoadReport `json:"last_load,omitempty"`
+	History             []Outcome         `json:"history"`
+}
+
+// Detailed is implemented by the sources giving a detailed status
+type Detailed interface {
+	GetDetails() Details
+}
+
+// Inventory is implemented by the sources able to describe the data they serve
+type Inventory interface {
+	// LoadedItems returns the number of items currently served
+	LoadedItems() int
+
+	// URI returns the location of the data, with its credentials
+	URI() url.URL
+}
+
+// Reporter is implemented by the sources reading csv files, skipping their invalid records
+type Reporter interface {
+	// LoadReport returns the report of the last file read, nil if none
+	LoadReport() *utils.LoadReport
+}
+
+// secretParameters are the query parameters hidden by RedactURI
+var secretParameters = []string{"token", "key", "password", "passphrase", "secret"}
+
+// RedactURI returns the uri without its credentials: the password of the user and the value of the query
+// parameters looking like a token are replaced by "xxxxx"
+func RedactURI(uri url.URL) string {
+	if uri.User != nil {
+		if _, ok := uri.User.Password(); ok {
+			uri.User = url.UserPassword(uri.User.Username(), "xxxxx")
+		}
+	}
+	query := uri.Query()
+	for name := range query {
+		for _, secret := range secretParameters {
+			if strings.Contains(strings.ToLower(name), secret) {
+				query.Set(name, "xxxxx")
+			}
+		}
+	}
+	if len(query) > 0 {
+		uri.RawQuery = query.Encode()
+	}
+	return uri.String()
+}
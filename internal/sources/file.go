@@ -0,0 +1,231 @@
+package sources
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/CanalTP/forseti/internal/utils"
+)
+
+// FileModule describes a module whose data are read periodically from a file (departures, parkings, equipments)
+type FileModule struct {
+	Name string
+	// Data names the data of the file in the usage of the parameters and in the errors
+	Data string
+	// Layout is the default layout of the csv file, nil for the files of another format
+	Layout *utils.Layout
+	// Options are the options reading the csv file with its default layout
+	Options utils.LoadDataOptions
+	// URIExample and ColumnsExample are given in the usage of the parameters, if set
+	URIExample     string
+	ColumnsExample string
+}
+
+// FileData is implemented by the contexts of the modules reading their data from a file, usually by embedding
+// a utils.FileState
+type FileData interface {
+	Snapshotter
+	PayloadLoader
+
+	// RefreshFile loads the file of the uri if it has been modified since its last load
+	RefreshFile(uri url.URL, connectionTimeout time.Duration) error
+	GetLastUpdate() time.Time
+	SetLoadDataOptions(options utils.LoadDataOptions)
+	GetLoadReport() *utils.LoadReport
+}
+
+// FileConfig is the configuration of the sources reading their data from a file
+type FileConfig struct {
+	URI               string        `mapstructure:"uri"`
+	Refresh           time.Duration `mapstructure:"refresh"`
+	ConnectionTimeout time.Duration `mapstructure:"connection-timeout"`
+	Backoff           `mapstructure:",squash"`
+	Snapshot          `mapstructure:",squash"`
+	utils.CSVConfig   `mapstructure:",squash"`
+}
+
+// Parameters returns the parameters of the module, the layout of the file can be configured for the csv files
+func (m FileModule) Parameters() []Parameter {
+	uriUsage := FileURIUsage
+	if m.URIExample != "" {
+		uriUsage += " \nexample: " + m.URIExample
+	}
+	parameters := []Parameter{
+		{Name: "uri", Flag: m.Name + "-uri", Default: "", Usage: uriUsage},
+		{Name: "refresh", Flag: m.Name + "-refresh", Default: 30 * time.Second,
+			Usage: fmt.Sprintf("time between refresh of %s data", m.Name)},
+	}
+	if m.Layout != nil {
+		columnsUsage := fmt.Sprintf("columns of the fields of the %s file, by index (from 0) or by name in the header, "+
+			"when its layout is not the default one", m.Data)
+		if m.ColumnsExample != "" {
+			columnsUsage += " \nexample: " + m.ColumnsExample
+		}
+		parameters = append(parameters,
+			Parameter{Name: "csv-columns", Flag: m.Name + "-csv-columns", Default: "", Usage: columnsUsage},
+			Parameter{Name: "csv-date-format", Flag: m.Name + "-csv-date-format", Default: "",
+				Usage: fmt.Sprintf("format of the dates of the %s file, as the date 2006-01-02 15:04:05 "+
+					"\nexample: 02/01/2006 15:04", m.Data)},
+			Parameter{Name: "csv-delimiter", Flag: m.Name + "-csv-delimiter", Default: "",
+				Usage: fmt.Sprintf("delimiter of the columns of the %s file, ; by default (tab for a tabulation)",
+					m.Data)},
+			Parameter{Name: "csv-max-error-ratio", Flag: m.Name + "-csv-max-error-ratio", Default: 0.0,
+				Usage: fmt.Sprintf("maximum ratio of invalid records skipped in the %s file, the file is rejected "+
+					"above (0 rejects the file at the first invalid record) \nexample: 0.05", m.Data)},
+		)
+	}
+	return append(parameters, ConnectionTimeout, BackoffInitial, BackoffMax, BackoffJitter, SnapshotDir,
+		SnapshotInterval)
+}
+
+// FileSource implements the sources whose data are read periodically from a file: their configuration and its
+// reload, the layout of the csv file, the snapshot and the ingest of their data. A source embeds a FileSource
+// and only declares its routes and the number of its items.
+type FileSource struct {
+	*Refresher
+	module            FileModule
+	instance          string
+	data              FileData
+	uri               url.URL
+	connectionTimeout time.Duration
+	csv               utils.CSVConfig
+	mutex             sync.RWMutex
+}
+
+// NewFileSource creates the file source of the data, the loader is the source embedding it
+func NewFileSource(loader Loader, module FileModule, data FileData, uri url.URL,
+	refresh, connectionTimeout time.Duration) *FileSource {
+	return &FileSource{
+		Refresher:         NewRefresher(loader, refresh, 0, true),
+		module:            module,
+		data:              data,
+		uri:               uri,
+		connectionTimeout: connectionTimeout,
+	}
+}
+
+// Configure applies the configuration of an instance to a new source, it returns false if the uri or the refresh
+// of the source are not configured
+func (s *FileSource) Configure(instance string, config *viper.Viper) (bool, error) {
+	var c FileConfig
+	if err := config.Unmarshal(&c); err != nil {
+		return false, err
+	}
+	if len(c.URI) == 0 || c.Refresh.Seconds() <= 0 {
+		return false, nil
+	}
+	uri, err := url.Parse(c.URI)
+	if err != nil {
+		return false, err
+	}
+	s.SetBackoff(c.Backoff)
+	s.SetSnapshot(c.Snapshot)
+	if err := s.setCSVConfig(c.CSVConfig); err != nil {
+		return false, err
+	}
+	s.SetRefresh(c.Refresh)
+	s.mutex.Lock()
+	s.instance, s.uri, s.connectionTimeout = instance, *uri, c.ConnectionTimeout
+	s.mutex.Unlock()
+	return true, nil
+}
+
+// setCSVConfig changes the layout of the file, the file is loaded again at the next refresh
+func (s *FileSource) setCSVConfig(config utils.CSVConfig) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if config == s.csv || s.module.Layout == nil {
+		return nil
+	}
+	options, err := config.Options(*s.module.Layout, s.module.Options)
+	if err != nil {
+		return fmt.Errorf("invalid layout of the %s file: %s", s.module.Data, err)
+	}
+	s.data.SetLoadDataOptions(options)
+	s.csv = config
+	return nil
+}
+
+func (s *FileSource) Name() string {
+	return s.module.Name
+}
+
+func (s *FileSource) Instance() string {
+	return s.instance
+}
+
+func (s *FileSource) Load() error {
+	s.mutex.RLock()
+	uri, connectionTimeout := s.uri, s.connectionTimeout
+	s.mutex.RUnlock()
+	return s.data.RefreshFile(uri, connectionTimeout)
+}
+
+// Reload restarts the refresh with the new configuration, the loaded data are kept.
+// The refresh is stopped if the uri is removed from the configuration.
+func (s *FileSource) Reload(config *viper.Viper) error {
+	var c FileConfig
+	if err := config.Unmarshal(&c); err != nil {
+		return err
+	}
+	s.SetBackoff(c.Backoff)
+	s.SetSnapshot(c.Snapshot)
+	if err := s.setCSVConfig(c.CSVConfig); err != nil {
+		return err
+	}
+	uri, err := url.Parse(c.URI)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.RLock()
+	unchanged := uri.String() == s.uri.String() && c.Refresh == s.GetRefresh() &&
+		c.ConnectionTimeout == s.connectionTimeout
+	s.mutex.RUnlock()
+	if unchanged && s.Running() {
+		return nil
+	}
+
+	s.Stop()
+	s.mutex.Lock()
+	s.uri, s.connectionTimeout = *uri, c.ConnectionTimeout
+	s.mutex.Unlock()
+	s.SetRefresh(c.Refresh)
+	if len(c.URI) > 0 && c.Refresh.Seconds() > 0 {
+		s.Start()
+	}
+	return nil
+}
+
+func (s *FileSource) GetStatus() Status {
+	status := s.Refresher.Status()
+	status.LastUpdate = s.data.GetLastUpdate()
+	return status
+}
+
+func (s *FileSource) URI() url.URL {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.uri
+}
+
+func (s *FileSource) WriteSnapshot(w io.Writer) error {
+	return s.data.WriteSnapshot(w)
+}
+
+func (s *FileSource) ReadSnapshot(r io.Reader) error {
+	return s.data.ReadSnapshot(r)
+}
+
+func (s *FileSource) LoadReport() *utils.LoadReport {
+	return s.data.GetLoadReport()
+}
+
+func (s *FileSource) LoadPayload(r io.Reader) error {
+	return s.data.LoadPayload(r)
+}
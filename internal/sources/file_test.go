@@ -0,0 +1,107 @@
+package sources
+
+import (
+	"io"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/CanalTP/forseti/internal/utils"
+)
+
+type fakeFileData struct {
+	utils.FileState
+	uri url.URL
+}
+
+func (d *fakeFileData) RefreshFile(uri url.URL, connectionTimeout time.Duration) error {
+	d.uri = uri
+	d.MarkUpdated()
+	return nil
+}
+func (d *fakeFileData) LoadPayload(r io.Reader) error   { return nil }
+func (d *fakeFileData) WriteSnapshot(w io.Writer) error { return nil }
+func (d *fakeFileData) ReadSnapshot(r io.Reader) error  { return nil }
+
+type fakeFileSource struct {
+	*FileSource
+}
+
+func (s *fakeFileSource) AddEntryPoints(r *gin.Engine) {}
+
+var fakeFileModule = FileModule{
+	Name:    "fake",
+	Data:    "fake data",
+	Layout:  &utils.Layout{Fields: []string{"id", "label"}},
+	Options: utils.LoadDataOptions{Delimiter: ';'},
+}
+
+func newFakeFileSource(data *fakeFileData) *fakeFileSource {
+	source := &fakeFileSource{}
+	source.FileSource = NewFileSource(source, fakeFileModule, data, url.URL{}, 0, 0)
+	return source
+}
+
+func TestFileModuleParameters(t *testing.T) {
+	assert := assert.New(t)
+
+	flags := func(module FileModule) []string {
+		var flags []string
+		for _, p := range module.Parameters() {
+			flags = append(flags, p.Flag)
+		}
+		return flags
+	}
+	assert.Contains(flags(fakeFileModule), "fake-uri")
+	assert.Contains(flags(fakeFileModule), "fake-csv-columns")
+	assert.Contains(flags(fakeFileModule), "snapshot-dir")
+	assert.Contains(flags(FileModule{Name: "xml"}), "xml-refresh")
+	assert.NotContains(flags(FileModule{Name: "xml"}), "xml-csv-columns")
+}
+
+func TestFileSourceConfigure(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	module := Module{Name: "fake", Parameters: fakeFileModule.Parameters()}
+	global := viper.New()
+	data := &fakeFileData{}
+	source := newFakeFileSource(data)
+	configured, err := source.Configure("tram", module.config(global, "tram"))
+	assert.False(configured)
+	assert.Nil(err)
+
+	global.Set("fake-csv-delimiter", "ab")
+	global.Set("fake-uri", "file:///tmp/fake.csv")
+	_, err = source.Configure("tram", module.config(global, "tram"))
+	assert.Error(err)
+
+	global.Set("fake-csv-delimiter", "tab")
+	global.Set("fake-tram-fake-refresh", "1m")
+	configured, err = source.Configure("tram", module.config(global, "tram"))
+	require.Nil(err)
+	assert.True(configured)
+	assert.Equal("fake", source.Name())
+	assert.Equal("tram", source.Instance())
+	assert.Equal(time.Minute, source.GetRefresh())
+	assert.Equal('\t', data.GetLoadDataOptions(fakeFileModule.Options).Delimiter)
+
+	require.Nil(source.Load())
+	assert.Equal("file:///tmp/fake.csv", data.uri.String())
+	assert.False(source.GetStatus().LastUpdate.IsZero())
+
+	// the layout and the uri are changed by a reload
+	global.Set("fake-csv-delimiter", ",")
+	global.Set("fake-uri", "file:///tmp/other.csv")
+	require.Nil(source.Reload(module.config(global, "tram")))
+	defer source.Stop()
+	assert.Equal(',', data.GetLoadDataOptions(fakeFileModule.Options).Delimiter)
+	uri := source.URI()
+	assert.Equal("file:///tmp/other.csv", uri.String())
+	assert.True(source.Running())
+}
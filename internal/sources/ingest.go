@@ -0,0 +1,51 @@
+package sources
+
+import (
+	"errors"
+	"io"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// ErrNoIngest is returned by Ingest for the sources whose data can not be pushed by their provider
+var ErrNoIngest = errors.New("the data of the source can not be pushed")
+
+// PayloadLoader is implemented by the sources whose data can be pushed by their provider instead of being
+// pulled, the payload has the format of the data loaded by the source (csv, xml, json or protobuf)
+type PayloadLoader interface {
+	// LoadPayload parses the payload then swaps its data with the served ones, the served data are kept if the
+	// payload is invalid
+	LoadPayload(r io.Reader) error
+}
+
+// Ingester is implemented by the sources accepting the data pushed to the ingest API
+type Ingester interface {
+	Ingest(payload io.Reader) error
+}
+
+// Ingest loads the data pushed by the provider of the source, they are never loaded concurrently with a
+// refresh. A successful ingest is kept in the status of the source as a refresh, a rejected payload is only
+// returned to the provider and does not delay the next refresh.
+func (r *Refresher) Ingest(payload io.Reader) error {
+	payloadLoader, ok := r.loader.(PayloadLoader)
+	if !ok {
+		return ErrNoIngest
+	}
+	key := Key(r.loader)
+	begin := time.Now()
+	r.loading.Lock()
+	err := payloadLoader.LoadPayload(payload)
+	r.loading.Unlock()
+	if err != nil {
+		IngestErrors.With(prometheus.Labels{"source": key}).Inc()
+		logrus.Warnf("Payload pushed to %s rejected: %s", key, err)
+		return err
+	}
+	r.record(begin, nil)
+	IngestDuration.With(prometheus.Labels{"source": key}).Observe(time.Since(begin).Seconds())
+	logrus.Debugf("%s data pushed", key)
+	r.saveSnapshot(false)
+	return nil
+}
@@ -0,0 +1,55 @@
+package sources
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type payloadLoader struct {
+	*fakeLoader
+	data string
+}
+
+func (l *payloadLoader) LoadPayload(r io.Reader) error {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if len(data) == 0 {
+		return fmt.Errorf("empty payload")
+	}
+	l.data = string(data)
+	return nil
+}
+
+func TestRefresherIngest(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	loader := &payloadLoader{fakeLoader: &fakeLoader{}}
+	loader.Refresher = NewRefresher(loader, time.Minute, 0, true)
+	loader.err = fmt.Errorf("unreachable")
+	assert.Error(loader.Refresh())
+
+	// the pushed data are loaded as a successful refresh
+	require.Nil(loader.Ingest(strings.NewReader("pushed")))
+	assert.Equal("pushed", loader.data)
+	details := loader.GetDetails()
+	assert.False(details.LastSuccess.IsZero())
+	assert.Equal(0, details.ConsecutiveFailures)
+	assert.Len(details.History, 2)
+
+	// a rejected payload keeps the data and the status
+	assert.EqualError(loader.Ingest(strings.NewReader("")), "empty payload")
+	assert.Equal("pushed", loader.data)
+	assert.Len(loader.GetDetails().History, 2)
+
+	assert.Equal(ErrNoIngest, newFakeLoader(time.Minute, true).Ingest(strings.NewReader("pushed")))
+}
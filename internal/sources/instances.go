@@ -0,0 +1,129 @@
+package sources
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Instances serves the routes of the sources of the named instances.
+// The routes of an instance are exposed under the name of the instance: the departures of the instance
+// "tram" are available at /tram/departures, the default instances keep their routes at the root.
+// As the sources can be added while serving (on a reload of the configuration), the router must be served
+// with Handler.
+type Instances struct {
+	engines map[string]*gin.Engine
+	sources map[string]Source
+	mutex   sync.RWMutex
+}
+
+func NewInstances() *Instances {
+	return &Instances{
+		engines: make(map[string]*gin.Engine),
+		sources: make(map[string]Source),
+	}
+}
+
+// AddEntryPoints declares the routes of the source on the router of its instance, the default instance
+// uses the main router. An instance can not be named after the first segment of a route of the main router
+// (status, metrics, departures...) as its routes would hide it.
+func (i *Instances) AddEntryPoints(r *gin.Engine, source Source) error {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	if _, ok := i.sources[Key(source)]; ok {
+		return fmt.Errorf("source %s is already declared", Key(source))
+	}
+
+	instance := source.Instance()
+	if instance == "" {
+		// the routes are first declared on a scratch router to check them before modifying the main one
+		scratch := gin.New()
+		source.AddEntryPoints(scratch)
+		for segment := range rootSegments(scratch.Routes()) {
+			if _, ok := i.engines[segment]; ok {
+				return fmt.Errorf("the routes of source %s collide with the instance %s", Key(source), segment)
+			}
+		}
+		i.sources[Key(source)] = source
+		source.AddEntryPoints(r)
+		return nil
+	}
+	engine, ok := i.engines[instance]
+	if !ok {
+		if rootSegments(r.Routes())[instance] {
+			return fmt.Errorf("the instance %s of source %s collides with the route /%s", instance,
+				Key(source), instance)
+		}
+		engine = gin.New()
+		i.engines[instance] = engine
+	}
+	i.sources[Key(source)] = source
+	source.AddEntryPoints(engine)
+	return nil
+}
+
+// RemoveSource stops serving the routes of the source of a named instance: the router of the instance is
+// rebuilt with its other sources, or removed if it has none left. The routes of the default instance can not be
+// removed from the main router, its sources are never removed by a reload.
+func (i *Instances) RemoveSource(source Source) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	delete(i.sources, Key(source))
+	instance := source.Instance()
+	if instance == "" {
+		return
+	}
+	delete(i.engines, instance)
+	for _, s := range i.sources {
+		if s.Instance() != instance {
+			continue
+		}
+		engine, ok := i.engines[instance]
+		if !ok {
+			engine = gin.New()
+			i.engines[instance] = engine
+		}
+		s.AddEntryPoints(engine)
+	}
+}
+
+// rootSegments returns the first segments of the paths of the routes
+func rootSegments(routes gin.RoutesInfo) map[string]bool {
+	segments := make(map[string]bool)
+	for _, route := range routes {
+		segment := strings.SplitN(strings.TrimPrefix(route.Path, "/"), "/", 2)[0]
+		segments[segment] = true
+	}
+	return segments
+}
+
+// Handler serves the router, the requests wait for the end of the addition of the routes of a source
+func (i *Instances) Handler(r *gin.Engine) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		i.mutex.RLock()
+		defer i.mutex.RUnlock()
+		r.ServeHTTP(w, req)
+	})
+}
+
+// Dispatch is a middleware forwarding the requests prefixed by the name of an instance to its router
+func (i *Instances) Dispatch() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		path := strings.TrimPrefix(c.Request.URL.Path, "/")
+		parts := strings.SplitN(path, "/", 2)
+		engine, ok := i.engines[parts[0]]
+		if !ok || len(parts) < 2 {
+			c.Next()
+			return
+		}
+		c.Request.URL.Path = "/" + parts[1]
+		c.Request.URL.RawPath = ""
+		engine.ServeHTTP(c.Writer, c.Request)
+		c.Abort()
+	}
+}
@@ -0,0 +1,107 @@
+package sources
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstancesDispatch(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	router := gin.New()
+	instances := NewInstances()
+	router.Use(instances.Dispatch())
+
+	require.Nil(instances.AddEntryPoints(router, &fakeSource{name: "fake", instance: "", uri: "default"}))
+	require.Nil(instances.AddEntryPoints(router, &fakeSource{name: "fake", instance: "tram", uri: "tram"}))
+	require.Nil(instances.AddEntryPoints(router, &fakeSource{name: "fake", instance: "bus", uri: "bus"}))
+	assert.NotNil(instances.AddEntryPoints(router, &fakeSource{name: "fake", instance: "tram", uri: "other"}))
+
+	for path, expected := range map[string]string{"/fake": "default", "/tram/fake": "tram", "/bus/fake": "bus"} {
+		w := httptest.NewRecorder()
+		c, _ := http.NewRequest("GET", path, nil)
+		router.ServeHTTP(w, c)
+		assert.Equal(http.StatusOK, w.Code, path)
+		assert.Equal(expected, w.Body.String(), path)
+	}
+
+	for _, path := range []string{"/tram", "/tram/other", "/metro/fake"} {
+		w := httptest.NewRecorder()
+		c, _ := http.NewRequest("GET", path, nil)
+		router.ServeHTTP(w, c)
+		assert.Equal(http.StatusNotFound, w.Code, path)
+	}
+}
+
+func TestInstancesRemoveSource(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	router := gin.New()
+	instances := NewInstances()
+	router.Use(instances.Dispatch())
+
+	fake := &fakeSource{name: "fake", instance: "tram", uri: "fake"}
+	other := &fakeSource{name: "other", instance: "tram", uri: "other"}
+	bus := &fakeSource{name: "fake", instance: "bus", uri: "bus"}
+	for _, source := range []*fakeSource{fake, other, bus} {
+		require.Nil(instances.AddEntryPoints(router, source))
+	}
+
+	// the other sources of the instance are still served
+	instances.RemoveSource(fake)
+	instances.RemoveSource(bus)
+	for path, expected := range map[string]int{"/tram/fake": http.StatusNotFound, "/tram/other": http.StatusOK,
+		"/bus/fake": http.StatusNotFound} {
+		w := httptest.NewRecorder()
+		c, _ := http.NewRequest("GET", path, nil)
+		router.ServeHTTP(w, c)
+		assert.Equal(expected, w.Code, path)
+	}
+
+	// a removed source can be declared again
+	require.Nil(instances.AddEntryPoints(router, &fakeSource{name: "fake", instance: "bus", uri: "new"}))
+	w := httptest.NewRecorder()
+	c, _ := http.NewRequest("GET", "/bus/fake", nil)
+	router.ServeHTTP(w, c)
+	assert.Equal("new", w.Body.String())
+}
+
+func TestInstancesCollidingWithRoutes(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	router := gin.New()
+	router.GET("/status", func(c *gin.Context) {})
+	instances := NewInstances()
+	router.Use(instances.Dispatch())
+
+	// an instance can not take over a route of the main router
+	assert.NotNil(instances.AddEntryPoints(router, &fakeSource{name: "fake", instance: "status", uri: "status"}))
+	require.Nil(instances.AddEntryPoints(router, &fakeSource{name: "fake", instance: "", uri: "default"}))
+	assert.NotNil(instances.AddEntryPoints(router, &fakeSource{name: "other", instance: "fake", uri: "fake"}))
+
+	// nor a source declared after it
+	require.Nil(instances.AddEntryPoints(router, &fakeSource{name: "fake", instance: "tram", uri: "tram"}))
+	assert.NotNil(instances.AddEntryPoints(router, &fakeSource{name: "tram", instance: "", uri: "default"}))
+
+	for path, expected := range map[string]string{"/fake": "default", "/tram/fake": "tram"} {
+		w := httptest.NewRecorder()
+		c, _ := http.NewRequest("GET", path, nil)
+		router.ServeHTTP(w, c)
+		assert.Equal(http.StatusOK, w.Code, path)
+		assert.Equal(expected, w.Body.String(), path)
+	}
+	for _, path := range []string{"/status/fake", "/fake/other", "/tram"} {
+		w := httptest.NewRecorder()
+		c, _ := http.NewRequest("GET", path, nil)
+		router.ServeHTTP(w, c)
+		assert.Equal(http.StatusNotFound, w.Code, path)
+	}
+}
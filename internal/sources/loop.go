@@ -0,0 +1,67 @@
+package sources
+
+import (
+	"context"
+	"sync"
+)
+
+// Loop runs the refresh loop of a source in a goroutine, the loop can be stopped and started again
+// (when the configuration of the source is reloaded) without losing the data of the source
+type Loop struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+	mutex  sync.Mutex
+}
+
+// Start launches the loop, the function must return when its context is cancelled.
+// Nothing is done if the loop is already running.
+func (l *Loop) Start(run func(ctx context.Context)) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.cancel != nil {
+		select {
+		case <-l.done:
+			l.cancel()
+		default:
+			return
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	l.cancel = cancel
+	l.done = done
+	go func() {
+		defer close(done)
+		run(ctx)
+	}()
+}
+
+// Stop cancels the loop and waits for its end
+func (l *Loop) Stop() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.cancel == nil {
+		return
+	}
+
+	l.cancel()
+	<-l.done
+	l.cancel = nil
+	l.done = nil
+}
+
+// Running returns true if the loop has been started and has neither been stopped nor returned
+func (l *Loop) Running() bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.cancel == nil {
+		return false
+	}
+	select {
+	case <-l.done:
+		return false
+	default:
+		return true
+	}
+}
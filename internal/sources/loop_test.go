@@ -0,0 +1,43 @@
+package sources
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoop(t *testing.T) {
+	assert := assert.New(t)
+
+	var loop Loop
+	assert.False(loop.Running())
+
+	runs := 0
+	run := func(ctx context.Context) {
+		runs++
+		<-ctx.Done()
+	}
+	loop.Start(run)
+	assert.True(loop.Running())
+
+	// a running loop is not started twice
+	loop.Start(run)
+	loop.Stop()
+	assert.False(loop.Running())
+	assert.Equal(1, runs)
+
+	// a stopped loop can be started again
+	loop.Start(run)
+	assert.True(loop.Running())
+	loop.Stop()
+	loop.Stop()
+	assert.Equal(2, runs)
+
+	// a loop which has returned by itself is no longer running
+	done := make(chan struct{})
+	loop.Start(func(ctx context.Context) { close(done) })
+	<-done
+	assert.Eventually(func() bool { return !loop.Running() }, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,45 @@
+package sources
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	RefreshDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "forseti",
+		Subsystem: "sources",
+		Name:      "refresh_durations_seconds",
+		Help:      "duration of the refresh of the data of a source.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 1.5, 15),
+	},
+		[]string{"source"},
+	)
+
+	RefreshErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "forseti",
+		Subsystem: "sources",
+		Name:      "refresh_errors",
+		Help:      "number of failed refreshes of the data of a source",
+	},
+		[]string{"source"},
+	)
+
+	IngestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "forseti",
+		Subsystem: "sources",
+		Name:      "ingest_durations_seconds",
+		Help:      "duration of the loading of the data pushed to a source.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 1.5, 15),
+	},
+		[]string{"source"},
+	)
+
+	IngestErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "forseti",
+		Subsystem: "sources",
+		Name:      "ingest_errors",
+		Help:      "number of rejected payloads pushed to a source",
+	},
+		[]string{"source"},
+	)
+)
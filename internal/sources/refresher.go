@@ -0,0 +1,249 @@
+package sources
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// Loader is implemented by the sources whose data are refreshed periodically by a Refresher
+type Loader interface {
+	Source
+
+	// Load fetches and parses the data of the source, then swaps them with the served ones
+	Load() error
+}
+
+// ReferenceLoader is implemented by the sources whose data depend on reference data loaded at start-up
+// (ex: the courses and the stop points of Oditi), a forced refresh reloads them before the data
+type ReferenceLoader interface {
+	LoadReferences() error
+}
+
+// Refresher calls periodically the Load method of a source. It implements for every source the refresh loop,
+// its activation (the data are kept but no longer refreshed when deactivated), its status and its metrics.
+// A source embeds a Refresher to get its Start, Stop, ManageRefreshStatus and GetDetails methods.
+type Refresher struct {
+	loader       Loader
+	refresh      time.Duration
+	backoff      Backoff
+	snapshot     Snapshot
+	delay        time.Duration
+	active       bool
+	stopped      bool
+	restored     bool
+	stale        bool
+	dirty        bool
+	lastSnapshot time.Time
+	lastSuccess  time.Time
+	lastAttempt  time.Time
+	lastError    string
+	failures     int
+	history      []Outcome
+	reschedule   chan struct{}
+	loop         Loop
+	loading      sync.Mutex
+	mutex        sync.RWMutex
+}
+
+// NewRefresher creates the refresher of a source, the first load is done after the delay
+func NewRefresher(loader Loader, refresh, delay time.Duration, active bool) *Refresher {
+	return &Refresher{
+		loader:     loader,
+		refresh:    refresh,
+		delay:      delay,
+		active:     active,
+		reschedule: make(chan struct{}, 1),
+	}
+}
+
+// Start launches the refresh loop, nothing is done if it is already running. On the first start, the data
+// are restored from the snapshot of the source, if any.
+func (r *Refresher) Start() {
+	r.restoreSnapshot()
+	r.mutex.Lock()
+	r.stopped = false
+	r.mutex.Unlock()
+	r.loop.Start(r.run)
+}
+
+// Stop ends the refresh loop, waits for the end of the current load and saves the data refreshed since the
+// last snapshot
+func (r *Refresher) Stop() {
+	r.loop.Stop()
+	r.mutex.Lock()
+	r.stopped = true
+	r.mutex.Unlock()
+	r.saveSnapshot(true)
+}
+
+// Running returns true if the refresh loop is running
+func (r *Refresher) Running() bool {
+	return r.loop.Running()
+}
+
+// ManageRefreshStatus activates or deactivates the periodic refresh, the loaded data are still served
+func (r *Refresher) ManageRefreshStatus(activate bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.active = activate
+}
+
+// Active returns true if the periodic refresh is activated
+func (r *Refresher) Active() bool {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.active
+}
+
+func (r *Refresher) GetRefresh() time.Duration {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.refresh
+}
+
+// SetRefresh changes the time between two loads, the current wait is shortened or extended accordingly
+func (r *Refresher) SetRefresh(refresh time.Duration) {
+	r.mutex.Lock()
+	r.refresh = refresh
+	r.mutex.Unlock()
+	notify(r.reschedule)
+}
+
+// Refresh loads the data of the source now
+func (r *Refresher) Refresh() error {
+	return r.refreshData(false)
+}
+
+// ForceRefresh reloads now the reference data of the source, if any, then its data. It is done even if the
+// periodic refresh is deactivated.
+func (r *Refresher) ForceRefresh() error {
+	return r.refreshData(true)
+}
+
+func (r *Refresher) refreshData(references bool) error {
+	key := Key(r.loader)
+	begin := time.Now()
+	err := r.load(references)
+	r.record(begin, err)
+	if err != nil {
+		RefreshErrors.With(prometheus.Labels{"source": key}).Inc()
+		logrus.Errorf("Error while reloading %s data: %s", key, err)
+		return err
+	}
+	RefreshDuration.With(prometheus.Labels{"source": key}).Observe(time.Since(begin).Seconds())
+	logrus.Debugf("%s data updated", key)
+	r.saveSnapshot(false)
+	return nil
+}
+
+// Status returns the state of the refresh, without the time of the last update which is known by the source
+func (r *Refresher) Status() Status {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return Status{
+		RefreshActive: r.active && !r.stopped,
+		RefreshTime:   r.refresh.String(),
+		Stale:         r.stale,
+	}
+}
+
+// GetDetails returns the status of the source with the outcome of its last refreshes, the loaded items and
+// the redacted uri are given by the sources implementing Inventory, the report of the last file read by the sources
+// implementing Reporter
+func (r *Refresher) GetDetails() Details {
+	details := Details{Status: r.loader.GetStatus()}
+	if inventory, ok := r.loader.(Inventory); ok {
+		details.LoadedItems = inventory.LoadedItems()
+		if uri := inventory.URI(); len(uri.String()) > 0 {
+			details.URI = RedactURI(uri)
+		}
+	}
+	if reporter, ok := r.loader.(Reporter); ok {
+		details.LastLoad = reporter.LoadReport()
+	}
+
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	details.LastSuccess = r.lastSuccess
+	details.LastAttempt = r.lastAttempt
+	details.LastError = r.lastError
+	details.ConsecutiveFailures = r.failures
+	details.History = make([]Outcome, len(r.history))
+	copy(details.History, r.history)
+	return details
+}
+
+// load calls the loader, the loads of a source are never done concurrently
+func (r *Refresher) load(references bool) error {
+	r.loading.Lock()
+	defer r.loading.Unlock()
+	if referenceLoader, ok := r.loader.(ReferenceLoader); ok && references {
+		if err := referenceLoader.LoadReferences(); err != nil {
+			return fmt.Errorf("loading reference data: %s", err)
+		}
+	}
+	return r.loader.Load()
+}
+
+// record keeps the outcome of a refresh, the most recent first
+func (r *Refresher) record(begin time.Time, err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	outcome := Outcome{Time: begin, Duration: time.Since(begin).String()}
+	r.lastAttempt = begin
+	if err != nil {
+		outcome.Error = err.Error()
+		r.lastError = err.Error()
+		r.failures++
+	} else {
+		r.lastSuccess = begin
+		r.failures = 0
+		r.stale = false
+		r.dirty = true
+	}
+	r.history = append([]Outcome{outcome}, r.history...)
+	if len(r.history) > HistorySize {
+		r.history = r.history[:HistorySize]
+	}
+}
+
+func (r *Refresher) run(ctx context.Context) {
+	ok := r.wait(ctx, func() time.Duration { return r.delay })
+	for ok {
+		if r.Active() {
+			_ = r.Refresh()
+		}
+		ok = r.wait(ctx, r.nextWait())
+	}
+}
+
+// wait waits for the period, which is read again when changed by SetRefresh. It returns false if the wait is
+// ended by the cancellation of the context.
+func (r *Refresher) wait(ctx context.Context, period func() time.Duration) bool {
+	begin := time.Now()
+	for {
+		timer := time.NewTimer(time.Until(begin.Add(period())))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return false
+		case <-timer.C:
+			return true
+		case <-r.reschedule:
+			timer.Stop()
+		}
+	}
+}
+
+// notify sends a signal on the channel without blocking, a pending signal is enough
+func notify(c chan struct{}) {
+	select {
+	case c <- struct{}{}:
+	default:
+	}
+}
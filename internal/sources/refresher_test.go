@@ -0,0 +1,136 @@
+package sources
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeLoader struct {
+	*Refresher
+	loads         int32
+	references    int32
+	err           error
+	referencesErr error
+}
+
+func (l *fakeLoader) Name() string                     { return "fake" }
+func (l *fakeLoader) Instance() string                 { return "test" }
+func (l *fakeLoader) Reload(config *viper.Viper) error { return nil }
+func (l *fakeLoader) GetStatus() Status                { return l.Status() }
+func (l *fakeLoader) AddEntryPoints(r *gin.Engine)     {}
+
+func (l *fakeLoader) Load() error {
+	atomic.AddInt32(&l.loads, 1)
+	return l.err
+}
+
+func (l *fakeLoader) LoadReferences() error {
+	atomic.AddInt32(&l.references, 1)
+	return l.referencesErr
+}
+
+func (l *fakeLoader) References() int {
+	return int(atomic.LoadInt32(&l.references))
+}
+
+func (l *fakeLoader) Loads() int {
+	return int(atomic.LoadInt32(&l.loads))
+}
+
+func newFakeLoader(refresh time.Duration, active bool) *fakeLoader {
+	loader := &fakeLoader{}
+	loader.Refresher = NewRefresher(loader, refresh, 0, active)
+	return loader
+}
+
+func TestRefresherRefresh(t *testing.T) {
+	assert := assert.New(t)
+
+	loader := newFakeLoader(time.Minute, true)
+	assert.Nil(loader.Refresh())
+	assert.Equal(1, loader.Loads())
+
+	loader.err = fmt.Errorf("unreachable")
+	assert.Error(loader.Refresh())
+	assert.Equal(2, loader.Loads())
+}
+
+func TestRefresherLoop(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	loader := newFakeLoader(10*time.Millisecond, true)
+	loader.Start()
+	require.True(loader.Running())
+	require.Eventually(func() bool { return loader.Loads() >= 2 }, time.Second, 5*time.Millisecond)
+
+	status := loader.Status()
+	assert.True(status.RefreshActive)
+	assert.Equal("10ms", status.RefreshTime)
+
+	// a deactivated refresher keeps running without loading the data
+	loader.ManageRefreshStatus(false)
+	assert.False(loader.Status().RefreshActive)
+	time.Sleep(20 * time.Millisecond)
+	loads := loader.Loads()
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(loads, loader.Loads())
+	assert.True(loader.Running())
+
+	loader.ManageRefreshStatus(true)
+	require.Eventually(func() bool { return loader.Loads() > loads }, time.Second, 5*time.Millisecond)
+
+	// a stopped refresher is not active
+	loader.Stop()
+	assert.False(loader.Running())
+	assert.False(loader.Status().RefreshActive)
+
+	loader.SetRefresh(time.Minute)
+	loader.Start()
+	assert.True(loader.Status().RefreshActive)
+	assert.Equal("1m0s", loader.Status().RefreshTime)
+	loader.Stop()
+}
+
+func TestRefresherReschedule(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	loader := newFakeLoader(time.Hour, true)
+	loader.Start()
+	defer loader.Stop()
+	require.Eventually(func() bool { return loader.Loads() == 1 }, time.Second, 5*time.Millisecond)
+
+	// the current wait is shortened by a new refresh period
+	loader.SetRefresh(10 * time.Millisecond)
+	require.Eventually(func() bool { return loader.Loads() > 2 }, time.Second, 5*time.Millisecond)
+	assert.Equal("10ms", loader.Status().RefreshTime)
+}
+
+func TestRefresherForceRefresh(t *testing.T) {
+	assert := assert.New(t)
+
+	// the reference data are loaded before the data, even when the refresh is deactivated
+	loader := newFakeLoader(time.Hour, false)
+	assert.Nil(loader.ForceRefresh())
+	assert.Equal(1, loader.References())
+	assert.Equal(1, loader.Loads())
+
+	// the periodic refresh does not reload the reference data
+	assert.Nil(loader.Refresh())
+	assert.Equal(1, loader.References())
+	assert.Equal(2, loader.Loads())
+
+	loader.referencesErr = fmt.Errorf("no courses")
+	err := loader.ForceRefresh()
+	assert.EqualError(err, "loading reference data: no courses")
+	assert.Equal(2, loader.Loads())
+	assert.Equal(1, loader.GetDetails().ConsecutiveFailures)
+}
@@ -0,0 +1,134 @@
+package sources
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Parameters of the snapshots of the data, shared by every module
+var (
+	SnapshotDir = Parameter{"snapshot-dir", "snapshot-dir", "",
+		"directory where the loaded data are saved, they are served at start-up until the first refresh " +
+			"(empty to disable the snapshots)"}
+	SnapshotInterval = Parameter{"snapshot-interval", "snapshot-interval", 5 * time.Minute,
+		"minimum time between two snapshots of the data of a source"}
+)
+
+// Snapshot configures the saving of the data of a source, it is embedded with the tag `mapstructure:",squash"`
+// in the configuration of the modules
+type Snapshot struct {
+	Dir      string        `mapstructure:"snapshot-dir"`
+	Interval time.Duration `mapstructure:"snapshot-interval"`
+}
+
+// Snapshotter is implemented by the sources able to save their data and to load them back
+type Snapshotter interface {
+	// WriteSnapshot writes the served data with the time of their last update
+	WriteSnapshot(w io.Writer) error
+
+	// ReadSnapshot replaces the served data by the ones written by WriteSnapshot
+	ReadSnapshot(r io.Reader) error
+}
+
+// SetSnapshot changes the saving of the data of the source
+func (r *Refresher) SetSnapshot(snapshot Snapshot) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.snapshot = snapshot
+}
+
+// snapshotPath returns the file of the snapshot of the source, empty if the snapshots are disabled
+func (r *Refresher) snapshotPath() string {
+	r.mutex.RLock()
+	dir := r.snapshot.Dir
+	r.mutex.RUnlock()
+	if _, ok := r.loader.(Snapshotter); !ok || dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, strings.Replace(Key(r.loader), ":", "_", -1)+".snapshot")
+}
+
+// restoreSnapshot loads the snapshot of the source once, if its data have not been loaded yet. The restored
+// data are stale until the next successful refresh.
+func (r *Refresher) restoreSnapshot() {
+	r.mutex.Lock()
+	restored := r.restored || !r.lastSuccess.IsZero()
+	r.restored = true
+	r.mutex.Unlock()
+	path := r.snapshotPath()
+	if restored || path == "" {
+		return
+	}
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		logrus.Errorf("Impossible to open the snapshot of %s: %s", Key(r.loader), err)
+		return
+	}
+	defer file.Close()
+
+	r.loading.Lock()
+	err = r.loader.(Snapshotter).ReadSnapshot(file)
+	r.loading.Unlock()
+	if err != nil {
+		logrus.Errorf("Impossible to read the snapshot of %s: %s", Key(r.loader), err)
+		return
+	}
+	r.mutex.Lock()
+	r.stale = true
+	r.mutex.Unlock()
+	logrus.Infof("%s data restored from %s, they are stale until the next refresh", Key(r.loader), path)
+}
+
+// saveSnapshot writes the data of the source if they have been refreshed since the last snapshot, and if the
+// snapshot interval is over or force is set
+func (r *Refresher) saveSnapshot(force bool) {
+	r.mutex.RLock()
+	due := r.dirty && (force || time.Since(r.lastSnapshot) >= r.snapshot.Interval)
+	r.mutex.RUnlock()
+	path := r.snapshotPath()
+	if !due || path == "" {
+		return
+	}
+
+	if err := writeSnapshot(r.loader.(Snapshotter), path); err != nil {
+		logrus.Errorf("Impossible to save the snapshot of %s: %s", Key(r.loader), err)
+		return
+	}
+	r.mutex.Lock()
+	r.dirty = false
+	r.lastSnapshot = time.Now()
+	r.mutex.Unlock()
+	logrus.Debugf("%s data saved in %s", Key(r.loader), path)
+}
+
+// writeSnapshot writes the snapshot in a temporary file renamed once complete, a snapshot being written is
+// never read
+func writeSnapshot(snapshotter Snapshotter, path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	file, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	if err := snapshotter.WriteSnapshot(file); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
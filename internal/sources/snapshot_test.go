@@ -0,0 +1,118 @@
+package sources
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type snapshotLoader struct {
+	*fakeLoader
+	data string
+}
+
+func (l *snapshotLoader) Load() error {
+	if err := l.fakeLoader.Load(); err != nil {
+		return err
+	}
+	l.data = fmt.Sprintf("load %d", l.Loads())
+	return nil
+}
+
+func (l *snapshotLoader) WriteSnapshot(w io.Writer) error {
+	_, err := io.WriteString(w, l.data)
+	return err
+}
+
+func (l *snapshotLoader) ReadSnapshot(r io.Reader) error {
+	data, err := ioutil.ReadAll(r)
+	l.data = string(data)
+	return err
+}
+
+func newSnapshotLoader(dir string, interval time.Duration) *snapshotLoader {
+	loader := &snapshotLoader{fakeLoader: &fakeLoader{}}
+	loader.Refresher = NewRefresher(loader, time.Hour, time.Hour, true)
+	loader.SetSnapshot(Snapshot{Dir: dir, Interval: interval})
+	return loader
+}
+
+func TestSnapshotSaveAndRestore(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "snapshots")
+	require.Nil(err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "fake_test.snapshot")
+
+	// the data are saved after a successful refresh, then at most once per interval
+	loader := newSnapshotLoader(dir, time.Hour)
+	require.Nil(loader.Refresh())
+	content, err := ioutil.ReadFile(path)
+	require.Nil(err)
+	assert.Equal("load 1", string(content))
+
+	require.Nil(loader.Refresh())
+	content, err = ioutil.ReadFile(path)
+	require.Nil(err)
+	assert.Equal("load 1", string(content))
+
+	// the last refreshed data are saved when the source is stopped
+	loader.Start()
+	loader.Stop()
+	content, err = ioutil.ReadFile(path)
+	require.Nil(err)
+	assert.Equal("load 2", string(content))
+
+	// a new source serves the data of the snapshot, stale until its first refresh
+	restored := newSnapshotLoader(dir, time.Hour)
+	restored.Start()
+	defer restored.Stop()
+	assert.Equal("load 2", restored.data)
+	assert.Equal(0, restored.Loads())
+	assert.True(restored.Status().Stale)
+
+	require.Nil(restored.Refresh())
+	assert.Equal("load 1", restored.data)
+	assert.False(restored.Status().Stale)
+}
+
+func TestSnapshotDisabled(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "snapshots")
+	require.Nil(err)
+	defer os.RemoveAll(dir)
+
+	loader := newSnapshotLoader("", 0)
+	require.Nil(loader.Refresh())
+	loader.Start()
+	loader.Stop()
+	files, err := ioutil.ReadDir(dir)
+	require.Nil(err)
+	assert.Empty(files)
+
+	// a failed refresh does not replace the snapshot
+	loader = newSnapshotLoader(dir, 0)
+	loader.err = fmt.Errorf("unreachable")
+	assert.Error(loader.Refresh())
+	loader.Stop()
+	files, err = ioutil.ReadDir(dir)
+	require.Nil(err)
+	assert.Empty(files)
+
+	// a missing snapshot leaves the source without data
+	loader.Start()
+	defer loader.Stop()
+	assert.Equal("", loader.data)
+	assert.False(loader.Status().Stale)
+}
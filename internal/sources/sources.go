@@ -0,0 +1,193 @@
+package sources
+
+// This module is used to declare the data modules available in forseti.
+// Each module registers itself at init with its name, its configuration parameters and a function
+// creating the source from its configuration. The sources are then started and exposed generically.
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// Source is a data module configured and running in forseti
+type Source interface {
+	// Name of the module of the source
+	Name() string
+
+	// Instance returns the name of the instance of the module, empty for the default instance
+	Instance() string
+
+	// Start launches the periodic refresh of the data
+	Start()
+
+	// Stop ends the periodic refresh of the data, the loaded data are still served
+	Stop()
+
+	// Reload applies a new configuration to the source while keeping its loaded data,
+	// an error is returned if the configuration can not be applied without restarting forseti
+	Reload(config *viper.Viper) error
+
+	// AddEntryPoints declares the routes serving the data of the source
+	AddEntryPoints(r *gin.Engine)
+
+	// GetStatus returns the state of the loading of the data
+	GetStatus() Status
+}
+
+// Activable is implemented by the sources whose periodic refresh can be activated or deactivated
+type Activable interface {
+	ManageRefreshStatus(activate bool)
+}
+
+// Refreshable is implemented by the sources refreshed periodically, their refresh is controlled by the admin API
+type Refreshable interface {
+	Activable
+
+	// ForceRefresh loads the data now, without waiting for the end of the refresh period
+	ForceRefresh() error
+
+	// SetRefresh changes the refresh period
+	SetRefresh(refresh time.Duration)
+}
+
+// Status of the loading of the data of a source
+type Status struct {
+	Source        string    `json:"source,omitempty"`
+	RefreshActive bool      `json:"refresh_active"`
+	RefreshTime   string    `json:"refresh_data,omitempty"`
+	LastUpdate    time.Time `json:"last_update"`
+	// Stale is set while the data restored from a snapshot have not been refreshed
+	Stale bool `json:"stale,omitempty"`
+}
+
+// Ready returns an error if the data of the source have never been loaded or, when its refresh is active, have
+// not been updated for more than staleness times the refresh period. The data restored from a snapshot and not
+// refreshed yet are not ready, unless acceptStale is set.
+func (s Status) Ready(staleness float64, acceptStale bool, now time.Time) error {
+	if s.LastUpdate.IsZero() {
+		return fmt.Errorf("no data loaded")
+	}
+	if s.Stale && !acceptStale {
+		return fmt.Errorf("data restored from the snapshot, not refreshed yet")
+	}
+	if !s.RefreshActive {
+		return nil
+	}
+	refresh, err := time.ParseDuration(s.RefreshTime)
+	if err != nil || refresh <= 0 {
+		return nil
+	}
+	if age := now.Sub(s.LastUpdate); age > time.Duration(staleness*float64(refresh)) {
+		return fmt.Errorf("data not updated for %s", age.Truncate(time.Second))
+	}
+	return nil
+}
+
+// Module describes a type of source
+type Module struct {
+	Name       string
+	Parameters []Parameter
+
+	// New creates a source from its configuration, it returns nil if the module is not configured
+	New func(instance string, config *viper.Viper) (Source, error)
+}
+
+var (
+	modules      = make(map[string]Module)
+	modulesMutex sync.RWMutex
+)
+
+// Register makes a module available in forseti, it panics if a module with the same name already exists
+func Register(module Module) {
+	modulesMutex.Lock()
+	defer modulesMutex.Unlock()
+
+	if _, ok := modules[module.Name]; ok {
+		panic(fmt.Sprintf("module %s is already registered", module.Name))
+	}
+	modules[module.Name] = module
+}
+
+// Modules returns the registered modules sorted by name
+func Modules() []Module {
+	modulesMutex.RLock()
+	defer modulesMutex.RUnlock()
+
+	result := make([]Module, 0, len(modules))
+	for _, m := range modules {
+		result = append(result, m)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// Key identifies a source: the name of its module followed by the name of its instance if any
+func Key(source Source) string {
+	return key(source.Name(), source.Instance())
+}
+
+func key(module, instance string) string {
+	if instance == "" {
+		return module
+	}
+	return module + ":" + instance
+}
+
+// NewSources creates the default source of every configured module and the sources of the named instances,
+// the sources in error are skipped
+func NewSources(config *viper.Viper) []Source {
+	created, _ := Reload(config, nil)
+	return created
+}
+
+// Reload applies the configuration to the running sources and returns the sources to create and the ones to
+// remove: the running sources are reloaded with their new configuration, the ones which are no longer declared
+// are stopped and the sources of the newly configured modules or instances are created
+func Reload(config *viper.Viper, running []Source) (created, removed []Source) {
+	instances, err := getInstances(config)
+	if err != nil {
+		logrus.Error(err)
+	}
+	runningByKey := make(map[string]Source)
+	for _, source := range running {
+		runningByKey[Key(source)] = source
+	}
+
+	created = make([]Source, 0)
+	for _, m := range Modules() {
+		for _, instance := range append([]string{""}, instances[m.Name]...) {
+			k := key(m.Name, instance)
+			if source, ok := runningByKey[k]; ok {
+				delete(runningByKey, k)
+				if err := source.Reload(m.config(config, instance)); err != nil {
+					logrus.Errorf("Impossible to reload source %s: %s", k, err)
+				}
+				continue
+			}
+			source, err := m.New(instance, m.config(config, instance))
+			if err != nil {
+				logrus.Errorf("Impossible to create source %s: %s", k, err)
+				continue
+			}
+			if source == nil {
+				logrus.Debugf("%s is disabled", k)
+				continue
+			}
+			created = append(created, source)
+		}
+	}
+	for k, source := range runningByKey {
+		logrus.Infof("%s is no longer declared, its refresh is stopped", k)
+		source.Stop()
+		removed = append(removed, source)
+	}
+	return created, removed
+}
@@ -0,0 +1,361 @@
+package sources
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeSource struct {
+	name     string
+	instance string
+	uri      string
+	stopped  bool
+}
+
+func (s *fakeSource) Name() string     { return s.name }
+func (s *fakeSource) Instance() string { return s.instance }
+func (s *fakeSource) Start()           {}
+func (s *fakeSource) Stop()            { s.stopped = true }
+func (s *fakeSource) Reload(config *viper.Viper) error {
+	if config.GetString("uri") == "error" {
+		return fmt.Errorf("wrong uri")
+	}
+	s.uri = config.GetString("uri")
+	return nil
+}
+func (s *fakeSource) GetStatus() Status {
+	return Status{}
+}
+func (s *fakeSource) AddEntryPoints(r *gin.Engine) {
+	r.GET("/"+s.name, func(c *gin.Context) {
+		c.String(http.StatusOK, s.uri)
+	})
+}
+
+func newFakeSource(instance string, config *viper.Viper) (Source, error) {
+	uri := config.GetString("uri")
+	if uri == "" {
+		return nil, nil
+	}
+	if uri == "error" {
+		return nil, fmt.Errorf("wrong uri")
+	}
+	return &fakeSource{name: "fake", instance: instance, uri: uri}, nil
+}
+
+var fixtureDir string
+
+func TestMain(m *testing.M) {
+
+	fixtureDir = os.Getenv("FIXTUREDIR")
+	if fixtureDir == "" {
+		panic("$FIXTUREDIR isn't set")
+	}
+
+	os.Exit(m.Run())
+}
+
+func resetModules() {
+	modulesMutex.Lock()
+	defer modulesMutex.Unlock()
+	modules = make(map[string]Module)
+}
+
+func TestRegister(t *testing.T) {
+	assert := assert.New(t)
+	resetModules()
+
+	Register(Module{Name: "b", New: newFakeSource})
+	Register(Module{Name: "a", New: newFakeSource})
+	assert.Panics(func() { Register(Module{Name: "a", New: newFakeSource}) })
+
+	m := Modules()
+	assert.Len(m, 2)
+	assert.Equal("a", m[0].Name)
+	assert.Equal("b", m[1].Name)
+}
+
+func TestAddFlags(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	resetModules()
+
+	Register(Module{Name: "a", New: newFakeSource, Parameters: []Parameter{
+		{Name: "uri", Flag: "a-uri", Default: "", Usage: "uri"},
+		{Name: "active", Flag: "a-active", Default: false, Usage: "active"},
+		ConnectionTimeout,
+	}})
+	Register(Module{Name: "b", New: newFakeSource, Parameters: []Parameter{
+		{Name: "refresh", Flag: "b-refresh", Default: time.Minute, Usage: "refresh"},
+		ConnectionTimeout,
+	}})
+
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	require.NotPanics(func() { AddFlags(flags) })
+	for _, name := range []string{"a-uri", "a-active", "b-refresh", "connection-timeout"} {
+		assert.NotNil(flags.Lookup(name), name)
+	}
+	assert.Equal("1m0s", flags.Lookup("b-refresh").DefValue)
+
+	resetModules()
+	Register(Module{Name: "c", New: newFakeSource, Parameters: []Parameter{{Name: "n", Flag: "c-n", Default: 1}}})
+	assert.Panics(func() { AddFlags(pflag.NewFlagSet("test", pflag.ContinueOnError)) })
+}
+
+func TestModuleConfig(t *testing.T) {
+	assert := assert.New(t)
+
+	m := Module{Name: "a", New: newFakeSource, Parameters: []Parameter{
+		{Name: "uri", Flag: "a-uri", Default: ""},
+		{Name: "refresh", Flag: "a-refresh", Default: 30 * time.Second},
+	}}
+
+	global := viper.New()
+	global.Set("a-uri", "file:///tmp")
+	global.Set("other-uri", "file:///other")
+
+	config := m.config(global, "")
+	assert.Equal("file:///tmp", config.GetString("uri"))
+	assert.Equal(30*time.Second, config.GetDuration("refresh"))
+	assert.False(config.IsSet("other-uri"))
+
+	// a named instance overrides the parameters of the module
+	global.Set("a-tram-a-uri", "file:///tram")
+	config = m.config(global, "tram")
+	assert.Equal("file:///tram", config.GetString("uri"))
+	assert.Equal(30*time.Second, config.GetDuration("refresh"))
+	config = m.config(global, "bus")
+	assert.Equal("file:///tmp", config.GetString("uri"))
+}
+
+func TestGetInstances(t *testing.T) {
+	assert := assert.New(t)
+
+	global := viper.New()
+	instances, err := getInstances(global)
+	assert.Nil(err)
+	assert.Empty(instances["parkings"])
+
+	global.Set("instances", []string{"parkings:tram,parkings:bus", "departures:tram"})
+	instances, err = getInstances(global)
+	assert.Nil(err)
+	assert.Equal([]string{"tram", "bus"}, instances["parkings"])
+	assert.Equal([]string{"tram"}, instances["departures"])
+
+	global.Set("instances", "parkings")
+	_, err = getInstances(global)
+	assert.NotNil(err)
+}
+
+func TestNewSources(t *testing.T) {
+	assert := assert.New(t)
+	resetModules()
+
+	Register(Module{Name: "configured", New: newFakeSource, Parameters: []Parameter{
+		{Name: "uri", Flag: "configured-uri", Default: ""}}})
+	Register(Module{Name: "disabled", New: newFakeSource, Parameters: []Parameter{
+		{Name: "uri", Flag: "disabled-uri", Default: ""}}})
+	Register(Module{Name: "error", New: newFakeSource, Parameters: []Parameter{
+		{Name: "uri", Flag: "error-uri", Default: ""}}})
+
+	global := viper.New()
+	global.Set("configured-uri", "file:///tmp")
+	global.Set("error-uri", "error")
+
+	sources := NewSources(global)
+	assert.Len(sources, 1)
+	assert.Equal("file:///tmp", sources[0].(*fakeSource).uri)
+
+	global.Set("instances", "configured:tram,disabled:tram")
+	global.Set("configured-tram-configured-uri", "file:///tram")
+	sources = NewSources(global)
+	assert.Len(sources, 2)
+	assert.Equal("fake", Key(sources[0]))
+	assert.Equal("fake:tram", Key(sources[1]))
+	assert.Equal("file:///tram", sources[1].(*fakeSource).uri)
+}
+
+func TestReload(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	resetModules()
+
+	Register(Module{Name: "fake", New: newFakeSource, Parameters: []Parameter{
+		{Name: "uri", Flag: "fake-uri", Default: ""}}})
+
+	global := viper.New()
+	global.Set("fake-uri", "file:///tmp")
+	global.Set("instances", "fake:tram,fake:bus")
+	running := NewSources(global)
+	require.Len(running, 3)
+
+	// the default source is reloaded, bus is removed and metro is added
+	global = viper.New()
+	global.Set("fake-uri", "file:///new")
+	global.Set("instances", "fake:tram,fake:metro")
+	created, removed := Reload(global, running)
+	require.Len(created, 1)
+	assert.Equal("fake:metro", Key(created[0]))
+	require.Len(removed, 1)
+	assert.Equal("fake:bus", Key(removed[0]))
+	assert.Equal("file:///new", created[0].(*fakeSource).uri)
+
+	sources := make(map[string]*fakeSource)
+	for _, s := range running {
+		sources[Key(s)] = s.(*fakeSource)
+	}
+	assert.Equal("file:///new", sources["fake"].uri)
+	assert.Equal("file:///new", sources["fake:tram"].uri)
+	assert.False(sources["fake"].stopped)
+	assert.True(sources["fake:bus"].stopped)
+
+	// a configuration in error keeps the running one
+	global.Set("fake-uri", "error")
+	running = []Source{sources["fake"], sources["fake:tram"], created[0]}
+	created, removed = Reload(global, running)
+	assert.Empty(created)
+	assert.Empty(removed)
+	assert.Equal("file:///new", sources["fake"].uri)
+}
+
+func TestReadConfigFile(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	resetModules()
+
+	Register(Module{Name: "fake", New: newFakeSource, Parameters: []Parameter{
+		{Name: "uri", Flag: "fake-uri", Default: ""},
+		{Name: "refresh", Flag: "fake-refresh", Default: 30 * time.Second},
+		{Name: "active", Flag: "fake-active", Default: false},
+	}})
+
+	for _, file := range []string{"config.yaml", "config.toml"} {
+		global := viper.New()
+		require.Nil(ReadConfigFile(global, fmt.Sprintf("%s/%s", fixtureDir, file)), file)
+		assert.Equal("info", global.GetString("log-level"), file)
+
+		sources := NewSources(global)
+		require.Len(sources, 2, file)
+		assert.Equal("file:///tmp", sources[0].(*fakeSource).uri, file)
+		assert.Equal("fake:tram", Key(sources[1]), file)
+		assert.Equal("file:///tram", sources[1].(*fakeSource).uri, file)
+
+		config := Modules()[0].config(global, "tram")
+		assert.Equal(time.Minute, config.GetDuration("refresh"), file)
+		assert.True(config.GetBool("active"), file)
+	}
+
+	// the environment overrides the configuration file
+	global := viper.New()
+	global.AutomaticEnv()
+	global.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
+	os.Setenv("FAKE_URI", "file:///env")
+	defer os.Unsetenv("FAKE_URI")
+	require.Nil(ReadConfigFile(global, fmt.Sprintf("%s/config.yaml", fixtureDir)))
+	assert.Equal("file:///env", Modules()[0].config(global, "").GetString("uri"))
+
+	assert.NotNil(ReadConfigFile(viper.New(), fmt.Sprintf("%s/config_invalid.yaml", fixtureDir)))
+	assert.NotNil(ReadConfigFile(viper.New(), fmt.Sprintf("%s/missing.yaml", fixtureDir)))
+	resetModules()
+	assert.NotNil(ReadConfigFile(viper.New(), fmt.Sprintf("%s/config.yaml", fixtureDir)))
+}
+
+func TestReadConfigFileSharedParameters(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	resetModules()
+	defer resetModules()
+
+	for _, name := range []string{"a", "b", "c"} {
+		Register(Module{Name: name, New: newFakeSource, Parameters: []Parameter{
+			{Name: "uri", Flag: name + "-uri", Default: ""},
+			ConnectionTimeout,
+			BackoffMax,
+		}})
+	}
+	file, err := ioutil.TempFile("", "config*.yaml")
+	require.Nil(err)
+	defer os.Remove(file.Name())
+	_, err = file.WriteString("sources:\n  a:\n    uri: file:///a\n    connection-timeout: 1s\n" +
+		"  b:\n    uri: file:///b\n    connection-timeout: 2s\n    backoff-max: 1h\n")
+	require.Nil(err)
+	require.Nil(file.Close())
+
+	global := viper.New()
+	require.Nil(ReadConfigFile(global, file.Name()))
+	a, b, c := Modules()[0].config(global, ""), Modules()[1].config(global, ""), Modules()[2].config(global, "")
+	assert.Equal(time.Second, a.GetDuration("connection-timeout"))
+	assert.Equal(30*time.Minute, a.GetDuration("backoff-max"))
+	assert.Equal(2*time.Second, b.GetDuration("connection-timeout"))
+	assert.Equal(time.Hour, b.GetDuration("backoff-max"))
+	assert.Equal(10*time.Second, c.GetDuration("connection-timeout"))
+
+	// the flag only applies to the modules which do not set the parameter
+	global.Set("connection-timeout", "5s")
+	assert.Equal(time.Second, Modules()[0].config(global, "").GetDuration("connection-timeout"))
+	assert.Equal(5*time.Second, Modules()[2].config(global, "").GetDuration("connection-timeout"))
+}
+
+func TestModuleConfigInstancesOfSameName(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	resetModules()
+	defer resetModules()
+
+	for _, name := range []string{"a", "b"} {
+		Register(Module{Name: name, New: newFakeSource, Parameters: []Parameter{
+			{Name: "uri", Flag: name + "-uri", Default: ""},
+			ConnectionTimeout,
+			SnapshotDir,
+		}})
+	}
+	file, err := ioutil.TempFile("", "config*.yaml")
+	require.Nil(err)
+	defer os.Remove(file.Name())
+	_, err = file.WriteString("sources:\n  a:\n    instances:\n      tram:\n        snapshot-dir: /a\n" +
+		"  b:\n    instances:\n      tram:\n        snapshot-dir: /b\n")
+	require.Nil(err)
+	require.Nil(file.Close())
+
+	global := viper.New()
+	require.Nil(ReadConfigFile(global, file.Name()))
+	global.Set("a-tram-connection-timeout", "1s")
+	a, b := Modules()[0].config(global, "tram"), Modules()[1].config(global, "tram")
+	assert.Equal(time.Second, a.GetDuration("connection-timeout"))
+	assert.Equal(10*time.Second, b.GetDuration("connection-timeout"))
+	assert.Equal("/a", a.GetString("snapshot-dir"))
+	assert.Equal("/b", b.GetString("snapshot-dir"))
+	assert.Equal("", Modules()[0].config(global, "").GetString("snapshot-dir"))
+}
+
+func TestStatusReady(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Now()
+
+	assert.EqualError(Status{}.Ready(3, false, now), "no data loaded")
+	assert.Error(Status{RefreshActive: true, RefreshTime: "1m0s"}.Ready(3, false, now))
+	recent := Status{RefreshActive: true, RefreshTime: "1m0s", LastUpdate: now.Add(-2 * time.Minute)}
+	assert.Nil(recent.Ready(3, false, now))
+	old := Status{RefreshActive: true, RefreshTime: "1m0s", LastUpdate: now.Add(-4 * time.Minute)}
+	assert.Error(old.Ready(3, false, now))
+	// the age of the data of an inactive refresh is not checked
+	assert.Nil(Status{RefreshActive: false, LastUpdate: now.Add(-time.Hour)}.Ready(3, false, now))
+	assert.Error(Status{RefreshActive: false, LastUpdate: now.Add(-time.Hour), Stale: true}.Ready(3, false, now))
+
+	// the data of a snapshot are ready only if the stale data are accepted, and not beyond the staleness
+	recent.Stale, old.Stale = true, true
+	assert.EqualError(recent.Ready(3, false, now), "data restored from the snapshot, not refreshed yet")
+	assert.Nil(recent.Ready(3, true, now))
+	assert.Error(old.Ready(3, true, now))
+}
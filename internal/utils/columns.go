@@ -0,0 +1,215 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// DefaultDateFormat is the format of the dates of the csv files read by the consumers
+const DefaultDateFormat = "2006-01-02 15:04:05"
+
+// Layout is the default layout of the records read by a LineConsumer: the name of the field of each column, empty
+// for an unused column, and the fields holding a date in DefaultDateFormat
+type Layout struct {
+	Fields     []string
+	DateFields []string
+}
+
+// ColumnMapping reads the records of a csv file with another layout as records with the default layout of a
+// LineConsumer. The columns of the fields are given by their index (from 0) or by their name in the header, the
+// first line of the file, the fields not mapped keep their default column. The dates are parsed with the format of
+// the file and given to the consumer in DefaultDateFormat.
+type ColumnMapping struct {
+	layout     Layout
+	columns    map[string]string
+	dateFormat string
+	indexes    []int // the columns given by their index, nil if the header is needed
+}
+
+// NewColumnMapping creates the mapping of the columns of the fields of the layout, given as
+// "field=column,field=column" (ex: "stop=COD_ARRET,datetime=5"), and the format of the dates as expected by
+// time.Parse (ex: "02/01/2006 15:04"). It returns nil if the file has the default layout.
+func NewColumnMapping(layout Layout, columns, dateFormat string) (*ColumnMapping, error) {
+	if columns == "" && dateFormat == "" {
+		return nil, nil
+	}
+	mapping := &ColumnMapping{layout: layout, columns: make(map[string]string), dateFormat: dateFormat}
+	if dateFormat == "" {
+		mapping.dateFormat = DefaultDateFormat
+	}
+	for _, entry := range strings.Split(columns, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, "=", 2)
+		field := strings.TrimSpace(parts[0])
+		if len(parts) != 2 || strings.TrimSpace(parts[1]) == "" {
+			return nil, fmt.Errorf("invalid column mapping %s: field=column expected", entry)
+		}
+		if layout.index(field) < 0 {
+			return nil, fmt.Errorf("unknown field %s, expected one of: %s", field, strings.Join(layout.names(), ", "))
+		}
+		mapping.columns[field] = strings.TrimSpace(parts[1])
+	}
+	if !mapping.ByName() {
+		indexes, err := mapping.resolve(nil)
+		if err != nil {
+			return nil, err
+		}
+		mapping.indexes = indexes
+	}
+	return mapping, nil
+}
+
+// ByName returns true if a column is given by its name, the first line of the file is then its header
+func (m *ColumnMapping) ByName() bool {
+	for _, column := range m.columns {
+		if _, err := strconv.Atoi(column); err != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// resolve returns the index of the column of each field, with the names of the columns given by the header
+func (m *ColumnMapping) resolve(header []string) ([]int, error) {
+	indexes := make([]int, len(m.layout.Fields))
+	for i, field := range m.layout.Fields {
+		indexes[i] = i
+		if field == "" {
+			// the unused columns of the default layout are empty
+			indexes[i] = -1
+			continue
+		}
+		column, ok := m.columns[field]
+		if !ok {
+			continue
+		}
+		if index, err := strconv.Atoi(column); err == nil {
+			if index < 0 {
+				return nil, fmt.Errorf("invalid column %s of the field %s", column, field)
+			}
+			indexes[i] = index
+			continue
+		}
+		indexes[i] = -1
+		for index, name := range header {
+			// the header of a file written by a spreadsheet may begin with a byte order mark
+			if strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")) == column {
+				indexes[i] = index
+				break
+			}
+		}
+		if indexes[i] < 0 {
+			return nil, fmt.Errorf("no column %s of the field %s in the header", column, field)
+		}
+	}
+	return indexes, nil
+}
+
+// apply returns the record in the default layout, the mapped columns missing in the record are an error. The
+// record ends with the last column found, as a record with the default layout whose optional columns are missing.
+func (m *ColumnMapping) apply(indexes []int, record []string, location *time.Location) ([]string, error) {
+	mapped := make([]string, len(indexes))
+	length := 0
+	for i, index := range indexes {
+		field := m.layout.Fields[i]
+		if index < 0 {
+			continue
+		}
+		if index >= len(record) {
+			if _, ok := m.columns[field]; ok {
+				return nil, fmt.Errorf("missing column %d of the field %s", index, field)
+			}
+			continue
+		}
+		length = i + 1
+		mapped[i] = record[index]
+		if m.layout.isDate(field) {
+			date, err := time.ParseInLocation(m.dateFormat, strings.TrimSpace(record[index]), location)
+			if err != nil {
+				return nil, fmt.Errorf("invalid date of the field %s: %s", field, err)
+			}
+			mapped[i] = date.In(location).Format(DefaultDateFormat)
+		}
+	}
+	return mapped[:length], nil
+}
+
+func (l Layout) index(field string) int {
+	for i, name := range l.Fields {
+		if name != "" && name == field {
+			return i
+		}
+	}
+	return -1
+}
+
+func (l Layout) names() []string {
+	var names []string
+	for _, name := range l.Fields {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func (l Layout) isDate(field string) bool {
+	for _, name := range l.DateFields {
+		if name == field {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseDelimiter returns the delimiter of the columns of a csv file given by a single character, or "tab"
+func ParseDelimiter(value string, defaultDelimiter rune) (rune, error) {
+	switch {
+	case value == "":
+		return defaultDelimiter, nil
+	case value == "tab" || value == `\t`:
+		return '\t', nil
+	case utf8.RuneCountInString(value) == 1:
+		delimiter, _ := utf8.DecodeRuneInString(value)
+		if delimiter == '"' || delimiter == '\r' || delimiter == '\n' {
+			return 0, fmt.Errorf("invalid delimiter %q", value)
+		}
+		return delimiter, nil
+	}
+	return 0, fmt.Errorf("invalid delimiter %s: a single character expected", value)
+}
+
+// CSVConfig is the layout of the csv files of a source, it is embedded with the tag `mapstructure:",squash"` in the
+// configuration of the modules reading csv files
+type CSVConfig struct {
+	Columns    string `mapstructure:"csv-columns"`
+	DateFormat string `mapstructure:"csv-date-format"`
+	Delimiter  string `mapstructure:"csv-delimiter"`
+	// MaxErrorRatio is the maximum ratio of invalid records skipped in a file, see LoadDataWithReport
+	MaxErrorRatio float64 `mapstructure:"csv-max-error-ratio"`
+}
+
+// Options returns the options reading the csv files with the layout of the configuration, the options of the
+// default layout of the consumer being given
+func (c CSVConfig) Options(layout Layout, defaults LoadDataOptions) (LoadDataOptions, error) {
+	options := defaults
+	delimiter, err := ParseDelimiter(c.Delimiter, defaults.Delimiter)
+	if err != nil {
+		return options, err
+	}
+	options.Delimiter = delimiter
+	if c.MaxErrorRatio < 0 || c.MaxErrorRatio > 1 {
+		return options, fmt.Errorf("invalid maximum error ratio %g: a ratio between 0 and 1 expected",
+			c.MaxErrorRatio)
+	}
+	options.MaxErrorRatio = c.MaxErrorRatio
+	if options.Mapping, err = NewColumnMapping(layout, c.Columns, c.DateFormat); err != nil {
+		return options, err
+	}
+	return options, nil
+}
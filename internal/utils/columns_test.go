@@ -0,0 +1,92 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testLayout = Layout{
+	Fields:     []string{"id", "", "date", "value"},
+	DateFields: []string{"date"},
+}
+
+type recordConsumer struct {
+	records [][]string
+}
+
+func (c *recordConsumer) Consume(record []string, location *time.Location) error {
+	c.records = append(c.records, record)
+	return nil
+}
+
+func (c *recordConsumer) Terminate() {}
+
+func loadMapped(t *testing.T, content, columns, dateFormat string, delimiter rune) ([][]string, error) {
+	mapping, err := NewColumnMapping(testLayout, columns, dateFormat)
+	require.Nil(t, err)
+	consumer := &recordConsumer{}
+	err = LoadDataWithOptions(strings.NewReader(content), consumer, LoadDataOptions{
+		Delimiter: delimiter,
+		Mapping:   mapping,
+	})
+	return consumer.records, err
+}
+
+func TestColumnMappingByIndex(t *testing.T) {
+	records, err := loadMapped(t, "42,x,17/09/2018 20:38\n43,y,17/09/2018 20:40\n", "id=0,value=1,date=2",
+		"02/01/2006 15:04", ',')
+	require.Nil(t, err)
+	assert.Equal(t, [][]string{
+		{"42", "", "2018-09-17 20:38:00", "x"},
+		{"43", "", "2018-09-17 20:40:00", "y"},
+	}, records)
+}
+
+func TestColumnMappingByName(t *testing.T) {
+	// the dates with a time zone are given in the time zone of the consumer
+	records, err := loadMapped(t, "\ufeffDATE;VALUE;ID\n2018-09-17T18:38:00Z;x;42\n", "id=ID,value=VALUE,date=DATE",
+		time.RFC3339, ';')
+	require.Nil(t, err)
+	assert.Equal(t, [][]string{{"42", "", "2018-09-17 20:38:00", "x"}}, records)
+
+	_, err = loadMapped(t, "DATE;ID\n2018-09-17T18:38:00Z;42\n", "id=ID,value=VALUE", "", ';')
+	assert.EqualError(t, err, "no column VALUE of the field value in the header")
+}
+
+func TestColumnMappingErrors(t *testing.T) {
+	_, err := NewColumnMapping(testLayout, "unknown=1", "")
+	assert.EqualError(t, err, "unknown field unknown, expected one of: id, date, value")
+	_, err = NewColumnMapping(testLayout, "id", "")
+	assert.EqualError(t, err, "invalid column mapping id: field=column expected")
+	_, err = NewColumnMapping(testLayout, "id=-1", "")
+	assert.EqualError(t, err, "invalid column -1 of the field id")
+	mapping, err := NewColumnMapping(testLayout, "", "")
+	assert.Nil(t, err)
+	assert.Nil(t, mapping)
+
+	_, err = loadMapped(t, "42;x\n", "value=5", "", ';')
+	assert.EqualError(t, err, "line 1: missing column 5 of the field value")
+	_, err = loadMapped(t, "42;x;2018-09-17\n", "", "02/01/2006", ';')
+	assert.Contains(t, err.Error(), "invalid date of the field date")
+
+	// the columns of the default layout missing at the end of the record are not given to the consumer
+	records, err := loadMapped(t, "42;x\n", "", "02/01/2006", ';')
+	require.Nil(t, err)
+	assert.Equal(t, [][]string{{"42"}}, records)
+}
+
+func TestParseDelimiter(t *testing.T) {
+	for value, expected := range map[string]rune{"": ';', ",": ',', "tab": '\t', `\t`: '\t', "|": '|'} {
+		delimiter, err := ParseDelimiter(value, ';')
+		require.Nil(t, err)
+		assert.Equal(t, expected, delimiter, value)
+	}
+	_, err := ParseDelimiter(",,", ';')
+	assert.Error(t, err)
+	_, err = ParseDelimiter(`"`, ';')
+	assert.Error(t, err)
+}
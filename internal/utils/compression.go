@@ -0,0 +1,157 @@
+package utils
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+)
+
+// Formats of the compressed files
+const (
+	uncompressed = ""
+	gzipFormat   = "gzip"
+	bzip2Format  = "bzip2"
+	zipFormat    = "zip"
+)
+
+// splitZipPath splits the path of an entry of a zip archive, /path/to/archive.zip/entry.csv, into the path of the
+// archive and the name of the entry. The entry is empty if the path is not in a zip archive.
+func splitZipPath(filePath string) (string, string) {
+	if i := strings.Index(strings.ToLower(filePath), ".zip/"); i >= 0 {
+		return filePath[:i+len(".zip")], filePath[i+len(".zip/"):]
+	}
+	return filePath, ""
+}
+
+// compressionFormat returns the format of the file given by its extension, or by its first bytes if the
+// extension is unknown
+func compressionFormat(filePath string, header []byte) string {
+	switch strings.ToLower(path.Ext(filePath)) {
+	case ".gz", ".gzip":
+		return gzipFormat
+	case ".bz2":
+		return bzip2Format
+	case ".zip":
+		return zipFormat
+	}
+	switch {
+	case bytes.HasPrefix(header, []byte{0x1f, 0x8b}):
+		return gzipFormat
+	case bytes.HasPrefix(header, []byte("BZh")):
+		return bzip2Format
+	case bytes.HasPrefix(header, []byte("PK\x03\x04")):
+		return zipFormat
+	}
+	return uncompressed
+}
+
+// decompress returns the decompressed content of the file, compressed with gzip, bzip2 or zip. The entry of a
+// zip archive is selected by its name, the only file of the archive is read if the name is empty. Closing the
+// returned reader closes the file.
+func decompress(file io.ReadCloser, filePath, entry string) (io.ReadCloser, error) {
+	buffered := bufio.NewReader(file)
+	// the error of a file shorter than the header is returned by the next read
+	header, _ := buffered.Peek(4)
+	format := compressionFormat(filePath, header)
+	if entry != "" {
+		format = zipFormat
+	}
+
+	switch format {
+	case gzipFormat:
+		reader, err := gzip.NewReader(buffered)
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("invalid gzip file %s: %s", filePath, err)
+		}
+		return &decompressedFile{Reader: reader, Closer: file}, nil
+	case bzip2Format:
+		return &decompressedFile{Reader: bzip2.NewReader(buffered), Closer: file}, nil
+	case zipFormat:
+		defer file.Close()
+		return openZipEntry(buffered, filePath, entry)
+	}
+	return &decompressedFile{Reader: buffered, Closer: file}, nil
+}
+
+// decompressedFile reads the decompressed content of a file, and closes the file
+type decompressedFile struct {
+	io.Reader
+	io.Closer
+}
+
+// openZipEntry copies the zip archive in a temporary file, its entries being read at their offset, and returns
+// the content of the entry. The temporary file is removed once the entry is closed.
+func openZipEntry(archive io.Reader, filePath, entry string) (io.ReadCloser, error) {
+	tmp, err := ioutil.TempFile("", "forseti-*.zip")
+	if err != nil {
+		return nil, err
+	}
+	removeTmp := func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}
+	size, err := io.Copy(tmp, archive)
+	if err != nil {
+		removeTmp()
+		return nil, err
+	}
+	reader, err := zip.NewReader(tmp, size)
+	if err != nil {
+		removeTmp()
+		return nil, fmt.Errorf("invalid zip file %s: %s", filePath, err)
+	}
+
+	selected, err := selectZipEntry(reader, entry)
+	if err != nil {
+		removeTmp()
+		return nil, fmt.Errorf("%s in %s", err, filePath)
+	}
+	content, err := selected.Open()
+	if err != nil {
+		removeTmp()
+		return nil, err
+	}
+	return &zipEntry{ReadCloser: content, remove: removeTmp}, nil
+}
+
+// selectZipEntry returns the entry of the archive with the name, or the only file of the archive if the name
+// is empty
+func selectZipEntry(reader *zip.Reader, name string) (*zip.File, error) {
+	var files []*zip.File
+	for _, file := range reader.File {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+		if name != "" && file.Name == name {
+			return file, nil
+		}
+		files = append(files, file)
+	}
+	if name != "" {
+		return nil, fmt.Errorf("no entry %s", name)
+	}
+	if len(files) != 1 {
+		return nil, fmt.Errorf("%d files, the name of the entry must be given", len(files))
+	}
+	return files[0], nil
+}
+
+type zipEntry struct {
+	io.ReadCloser
+	remove func()
+}
+
+func (e *zipEntry) Close() error {
+	err := e.ReadCloser.Close()
+	e.remove()
+	return err
+}
@@ -0,0 +1,120 @@
+package utils
+
+import (
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readFile(t *testing.T, rawURI string) (string, error) {
+	uri, err := url.Parse(rawURI)
+	require.Nil(t, err)
+	file, err := GetFile(*uri, defaultTimeout)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	content, err := ioutil.ReadAll(file)
+	return string(content), err
+}
+
+func TestCompressedFiles(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "compressed")
+	require.Nil(err)
+	defer os.RemoveAll(dir)
+
+	gzipFile, err := os.Create(filepath.Join(dir, "oneline.txt.gz"))
+	require.Nil(err)
+	writer := gzip.NewWriter(gzipFile)
+	_, err = writer.Write([]byte(oneline))
+	require.Nil(err)
+	require.Nil(writer.Close())
+	require.Nil(gzipFile.Close())
+	// a gzip file without extension is recognized by its first bytes
+	require.Nil(os.Link(gzipFile.Name(), filepath.Join(dir, "oneline")))
+
+	content, err := readFile(t, "file://"+gzipFile.Name())
+	require.Nil(err)
+	assert.Equal(oneline, content)
+	content, err = readFile(t, "file://"+filepath.Join(dir, "oneline"))
+	require.Nil(err)
+	assert.Equal(oneline, content)
+
+	content, err = readFile(t, fmt.Sprintf("file://%s/oneline.txt.bz2", fixtureDir))
+	require.Nil(err)
+	assert.Equal(oneline, content)
+
+	// a file with a wrong extension is not decompressed
+	require.Nil(ioutil.WriteFile(filepath.Join(dir, "plain.gz"), []byte(oneline), 0600))
+	_, err = readFile(t, "file://"+filepath.Join(dir, "plain.gz"))
+	assert.Error(err)
+	content, err = readFile(t, fmt.Sprintf("file://%s/oneline.txt", fixtureDir))
+	require.Nil(err)
+	assert.Equal(oneline, content)
+}
+
+func TestZipArchive(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "compressed")
+	require.Nil(err)
+	defer os.RemoveAll(dir)
+	tmp := filepath.Join(dir, "tmp")
+	require.Nil(os.Mkdir(tmp, 0700))
+	setEnv(t, "TMPDIR", tmp)
+
+	writeZip := func(name string, entries ...string) string {
+		file, err := os.Create(filepath.Join(dir, name))
+		require.Nil(err)
+		writer := zip.NewWriter(file)
+		for _, entry := range entries {
+			w, err := writer.Create(entry)
+			require.Nil(err)
+			_, err = fmt.Fprintf(w, "content of %s", entry)
+			require.Nil(err)
+		}
+		require.Nil(writer.Close())
+		require.Nil(file.Close())
+		return file.Name()
+	}
+	single := writeZip("single.zip", "departures.txt")
+	multiple := writeZip("multiple.zip", "mapping_stops.csv", "data/extraction_courses.csv")
+
+	// the only file of an archive is read without the name of its entry
+	content, err := readFile(t, "file://"+single)
+	require.Nil(err)
+	assert.Equal("content of departures.txt", content)
+	require.Nil(os.Rename(single, filepath.Join(dir, "single")))
+	content, err = readFile(t, "file://"+filepath.Join(dir, "single"))
+	require.Nil(err)
+	assert.Equal("content of departures.txt", content)
+
+	content, err = readFile(t, "file://"+multiple+"/data/extraction_courses.csv")
+	require.Nil(err)
+	assert.Equal("content of data/extraction_courses.csv", content)
+	content, err = readFile(t, "file://"+multiple+"/mapping_stops.csv")
+	require.Nil(err)
+	assert.Equal("content of mapping_stops.csv", content)
+
+	_, err = readFile(t, "file://"+multiple+"/unknown.csv")
+	assert.EqualError(err, fmt.Sprintf("no entry unknown.csv in %s", multiple))
+	_, err = readFile(t, "file://"+multiple)
+	assert.EqualError(err, fmt.Sprintf("2 files, the name of the entry must be given in %s", multiple))
+
+	// the temporary copies of the archives are removed
+	files, err := ioutil.ReadDir(tmp)
+	require.Nil(err)
+	assert.Empty(files)
+}
@@ -1,87 +1,251 @@
 package utils
 
 import (
-	"bytes"
+	"bufio"
+	"crypto/sha256"
 	"encoding/csv"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"hash"
 	"io"
 	"net/url"
 	"os"
+	"strings"
 	"time"
 
-	"github.com/pkg/sftp"
-	"golang.org/x/crypto/ssh"
-
 	"github.com/CanalTP/forseti/internal/data"
 )
 
 var location = "Europe/Paris"
 
-func GetFile(uri url.URL, connectionTimeout time.Duration) (io.Reader, error) {
-	if uri.Scheme == "sftp" {
-		return GetFileWithSftp(uri, connectionTimeout)
-	} else if uri.Scheme == "file" {
-		return GetFileWithFS(uri)
-	} else {
-		return nil, fmt.Errorf("Unsupported protocols %s", uri.Scheme)
-	}
+// ErrNotModified is returned by GetFileIfModified when the file has not changed since the given version
+var ErrNotModified = errors.New("file not modified")
 
+// FileVersion identifies the content of a file read by GetFileIfModified, it is given back to the next call
+// to skip an unchanged file. The files read with http(s) are versioned with the headers ETag and Last-Modified,
+// the local and SFTP files with their modification time and their size, and every file with the checksum of its
+// content once read.
+type FileVersion struct {
+	URI          string
+	Path         string // the file selected by the glob pattern of the uri
+	ETag         string
+	LastModified string
+	ModTime      time.Time
+	Size         int64
+	Checksum     string
 }
 
-func GetFileWithFS(uri url.URL) (io.Reader, error) {
-	file, err := os.Open(uri.Path)
-	if err != nil {
-		return nil, err
-	}
-	defer file.Close()
-	var buffer bytes.Buffer
-	if _, err = buffer.ReadFrom(file); err != nil {
-		return nil, err
-	}
-	return &buffer, nil
-}
-
-func GetFileWithSftp(uri url.URL, connectionTimeout time.Duration) (io.Reader, error) {
-	password, _ := uri.User.Password()
-	sshConfig := &ssh.ClientConfig{
-		User: uri.User.Username(),
-		Auth: []ssh.AuthMethod{
-			ssh.Password(password),
-		},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(), //nolint:gosec
-		Timeout:         connectionTimeout,
-	}
+// sameFile returns true if the modification time and the size of the files are known and equal
+func (v FileVersion) sameFile(other FileVersion) bool {
+	return !v.ModTime.IsZero() && v.Path == other.Path && v.ModTime.Equal(other.ModTime) && v.Size == other.Size
+}
 
-	sshClient, err := ssh.Dial("tcp", uri.Host, sshConfig)
-	if err != nil {
-		return nil, err
+// File streams the content of a file read by GetFileIfModified, the checksum of its content is computed while
+// it is read. It must be closed once read, to close the connection to its host.
+type File struct {
+	reader  io.ReadCloser
+	hash    hash.Hash
+	version FileVersion
+	done    func() error
+}
+
+func newFile(reader io.ReadCloser, version FileVersion, done func() error) *File {
+	return &File{reader: reader, hash: sha256.New(), version: version, done: done}
+}
+
+func (f *File) Read(p []byte) (int, error) {
+	n, err := f.reader.Read(p)
+	f.hash.Write(p[:n])
+	return n, err
+}
+
+func (f *File) Close() error {
+	return f.reader.Close()
+}
+
+// Version returns the version of the file, with the checksum of the content read: the file must have been read
+// entirely to compare it with the checksum of another version
+func (f *File) Version() FileVersion {
+	version := f.version
+	version.Checksum = hex.EncodeToString(f.hash.Sum(nil))
+	return version
+}
+
+// Done archives or deletes the file once its data have been loaded, as given by the parameters archive and delete
+// of its uri
+func (f *File) Done() error {
+	if f.done == nil {
+		return nil
 	}
-	defer sshClient.Close()
+	return f.done()
+}
 
-	// open an SFTP session over an existing ssh connection.
-	client, err := sftp.NewClient(sshClient)
-	if err != nil {
-		return nil, err
+// GetFile returns the content of the file of the uri, streamed from its host. It must be closed once read.
+func GetFile(uri url.URL, connectionTimeout time.Duration) (*File, error) {
+	return GetFileIfModified(uri, connectionTimeout, FileVersion{})
+}
+
+// GetFileIfModified returns the file of the uri, ErrNotModified is returned if the file has not changed since
+// the given version, read from the same uri. The modification time and the size of the local and SFTP files are
+// compared before their download, and the http(s) requests are conditional.
+//
+// The files compressed with gzip, bzip2 or zip, given by their extension or by their first bytes, are
+// decompressed. The entry of a zip archive is given by the path of the uri, /path/to/archive.zip/entry.csv.
+//
+// The name of a local or SFTP file can be a glob pattern, /path/to/extract_*.txt, to read the newest matching
+// file (see selectFile), archived or deleted once loaded (see processedAction). If no file matches,
+// ErrNotModified is returned.
+func GetFileIfModified(uri url.URL, connectionTimeout time.Duration, version FileVersion) (*File, error) {
+	rawURI := uri.String()
+	if version.URI != rawURI {
+		version = FileVersion{}
+	}
+	var reader io.ReadCloser
+	var err error
+	current := FileVersion{URI: rawURI}
+	var entry string
+	uri.Path, entry = splitZipPath(uri.Path)
+	uri.RawPath = ""
+	var done func() error
+	if hasGlob(uri.Path) {
+		selected, err := selectFile(uri, connectionTimeout)
+		if err == errNoMatch {
+			// no file has been dropped yet, or the file read before has been archived or deleted
+			return nil, ErrNotModified
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", uri.Path, err)
+		}
+		uri.Path, current.Path = selected, selected
+		if done, err = processedAction(uri, connectionTimeout); err != nil {
+			return nil, err
+		}
 	}
-	defer client.Close()
 
-	file, err := client.Open(uri.Path)
+	if uri.Scheme == "sftp" {
+		var info os.FileInfo
+		if info, err = StatSftpFile(uri, connectionTimeout); err != nil {
+			return nil, err
+		}
+		current.ModTime, current.Size = info.ModTime(), info.Size()
+		if version.sameFile(current) {
+			return nil, ErrNotModified
+		}
+		reader, err = GetFileWithSftp(uri, connectionTimeout)
+	} else if uri.Scheme == "file" {
+		var info os.FileInfo
+		if info, err = os.Stat(uri.Path); err != nil {
+			return nil, err
+		}
+		current.ModTime, current.Size = info.ModTime(), info.Size()
+		if version.sameFile(current) {
+			return nil, ErrNotModified
+		}
+		reader, err = GetFileWithFS(uri)
+	} else if uri.Scheme == "ftp" || uri.Scheme == "ftps" {
+		reader, err = GetFileWithFtp(uri, connectionTimeout)
+	} else if uri.Scheme == "http" || uri.Scheme == "https" {
+		reader, current, err = GetFileWithHTTP(uri, connectionTimeout, version)
+	} else if uri.Scheme == "s3" {
+		reader, current, err = GetFileWithS3(uri, connectionTimeout, version)
+	} else {
+		return nil, fmt.Errorf("Unsupported protocols %s", uri.Scheme)
+	}
 	if err != nil {
 		return nil, err
 	}
-	defer file.Close()
-	var buffer bytes.Buffer
-	if _, err = file.WriteTo(&buffer); err != nil {
+	if reader, err = decompress(reader, uri.Path, entry); err != nil {
 		return nil, err
 	}
-	return &buffer, nil
+	current.URI = rawURI
+	return newFile(reader, current, done), nil
+}
 
+func GetFileWithFS(uri url.URL) (io.ReadCloser, error) {
+	return os.Open(uri.Path)
 }
 
 type LoadDataOptions struct {
 	SkipFirstLine bool
 	Delimiter     rune
 	NbFields      int
+	// Mapping reads a file with another layout than the default one of the consumer, nil for the default layout
+	Mapping *ColumnMapping
+	// MaxErrorRatio is the maximum ratio of invalid records skipped in a file, 0 rejects any invalid record
+	MaxErrorRatio float64
+}
+
+// MaxLineErrors is the number of errors of the invalid records kept in the report of a file
+const MaxLineErrors = 10
+
+// LineError is the error of an invalid record of a file, with the number of its line
+type LineError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+// LoadReport describes the records read in a file by LoadDataWithReport, with the first errors of the invalid
+// records skipped
+type LoadReport struct {
+	Records int         `json:"records"`
+	Skipped int         `json:"skipped"`
+	Errors  []LineError `json:"errors,omitempty"`
+}
+
+// skip counts the invalid record, the error is returned with the number of its line if the invalid records are
+// not skipped
+func (r *LoadReport) skip(line int, err error, maxErrorRatio float64) error {
+	if maxErrorRatio <= 0 {
+		return fmt.Errorf("line %d: %s", line, err)
+	}
+	r.Skipped++
+	if len(r.Errors) < MaxLineErrors {
+		r.Errors = append(r.Errors, LineError{Line: line, Error: err.Error()})
+	}
+	return nil
+}
+
+// lineReader counts the lines read in a file, it returns at most one line at each read so that the csv.Reader
+// does not buffer the next lines: the number of lines read is then the line of the end of the last record.
+type lineReader struct {
+	reader  *bufio.Reader
+	pending []byte
+	err     error
+	inLine  bool
+	lines   int
+}
+
+func (l *lineReader) Read(p []byte) (int, error) {
+	if len(l.pending) == 0 {
+		if l.err != nil {
+			return 0, l.err
+		}
+		var err error
+		l.pending, err = l.reader.ReadSlice('\n')
+		if err != nil && err != bufio.ErrBufferFull {
+			l.err = err
+		}
+		if len(l.pending) == 0 {
+			return 0, l.err
+		}
+		if !l.inLine {
+			l.lines++
+		}
+		l.inLine = l.pending[len(l.pending)-1] != '\n'
+	}
+	n := copy(p, l.pending)
+	l.pending = l.pending[n:]
+	return n, nil
+}
+
+// startLine returns the line of the beginning of the record read last, a quoted field can span several lines
+func (l *lineReader) startLine(record []string) int {
+	line := l.lines
+	for _, field := range record {
+		line -= strings.Count(field, "\n")
+	}
+	return line
 }
 
 func LoadData(file io.Reader, lineConsumer data.LineConsumer) error {
@@ -94,35 +258,79 @@ func LoadData(file io.Reader, lineConsumer data.LineConsumer) error {
 }
 
 func LoadDataWithOptions(file io.Reader, lineConsumer data.LineConsumer, options LoadDataOptions) error {
+	_, err := LoadDataWithReport(file, lineConsumer, options)
+	return err
+}
 
+// LoadDataWithReport gives the records of the csv file to the consumer and returns the report of the invalid
+// records. Without MaxErrorRatio, the first invalid record rejects the file. Otherwise the invalid records are
+// skipped, and the file is rejected once read if their ratio is above MaxErrorRatio.
+func LoadDataWithReport(file io.Reader, lineConsumer data.LineConsumer, options LoadDataOptions) (LoadReport, error) {
+	var report LoadReport
 	location, err := time.LoadLocation(location)
 	if err != nil {
-		return err
+		return report, err
 	}
 
-	reader := csv.NewReader(file)
+	lines := &lineReader{reader: bufio.NewReader(file)}
+	reader := csv.NewReader(lines)
 	reader.Comma = options.Delimiter
 	reader.FieldsPerRecord = options.NbFields
 
+	var indexes []int
+	header := options.SkipFirstLine
+	if options.Mapping != nil {
+		indexes = options.Mapping.indexes
+		header = header || options.Mapping.ByName()
+	}
+
 	// Loop through lines & turn into object
 	for {
 		line, err := reader.Read()
 		if err == io.EOF {
 			break
+		}
+		if parseErr, ok := err.(*csv.ParseError); ok && !header {
+			// the reader goes on with the next record
+			report.Records++
+			if err = report.skip(parseErr.StartLine, parseErr.Err, options.MaxErrorRatio); err != nil {
+				return report, err
+			}
+			continue
 		} else if err != nil {
-			return err
+			return report, err
 		}
 
-		if options.SkipFirstLine {
-			options.SkipFirstLine = false
+		if header {
+			header = false
+			if options.Mapping != nil && options.Mapping.ByName() {
+				if indexes, err = options.Mapping.resolve(line); err != nil {
+					return report, err
+				}
+			}
 			continue
 		}
 
-		if err := lineConsumer.Consume(line, location); err != nil {
-			return err
+		report.Records++
+		lineNumber := lines.startLine(line)
+		if options.Mapping != nil {
+			line, err = options.Mapping.apply(indexes, line, location)
+		}
+		if err == nil {
+			err = lineConsumer.Consume(line, location)
+		}
+		if err != nil {
+			if err = report.skip(lineNumber, err, options.MaxErrorRatio); err != nil {
+				return report, err
+			}
 		}
 	}
 
+	if report.Skipped > 0 && float64(report.Skipped)/float64(report.Records) > options.MaxErrorRatio {
+		return report, fmt.Errorf("%d invalid records out of %d, above the maximum ratio of %g, first error "+
+			"at line %d: %s", report.Skipped, report.Records, options.MaxErrorRatio, report.Errors[0].Line,
+			report.Errors[0].Error)
+	}
 	lineConsumer.Terminate()
-	return nil
+	return report, nil
 }
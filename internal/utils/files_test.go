@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadDataWithReport(t *testing.T) {
+	// the third line has a missing column, the fifth one a bare quote
+	content := "1;a;2018-09-17 20:38:00;x\n2;b;2018-09-17 20:39:00;y\n3;c\n4;d;2018-09-17 20:40:00;z\n" +
+		"5;\"e;2018-09-17 20:41:00;w\n"
+	mapping, err := NewColumnMapping(testLayout, "value=3", "")
+	require.Nil(t, err)
+	options := LoadDataOptions{Delimiter: ';', NbFields: -1, Mapping: mapping}
+
+	consumer := &recordConsumer{}
+	_, err = LoadDataWithReport(strings.NewReader(content), consumer, options)
+	assert.EqualError(t, err, "line 3: missing column 3 of the field value")
+
+	options.MaxErrorRatio = 0.5
+	consumer = &recordConsumer{}
+	report, err := LoadDataWithReport(strings.NewReader(content), consumer, options)
+	require.Nil(t, err)
+	assert.Len(t, consumer.records, 3)
+	assert.Equal(t, 5, report.Records)
+	assert.Equal(t, 2, report.Skipped)
+	require.Len(t, report.Errors, 2)
+	assert.Equal(t, LineError{Line: 3, Error: "missing column 3 of the field value"}, report.Errors[0])
+	assert.Equal(t, 5, report.Errors[1].Line)
+
+	options.MaxErrorRatio = 0.25
+	report, err = LoadDataWithReport(strings.NewReader(content), &recordConsumer{}, options)
+	assert.EqualError(t, err, "2 invalid records out of 5, above the maximum ratio of 0.25, first error at "+
+		"line 3: missing column 3 of the field value")
+	assert.Equal(t, 2, report.Skipped)
+}
+
+func TestLoadDataWithReportMultilineRecords(t *testing.T) {
+	// the quoted field of the first record spans three lines, the lines are counted in the file
+	content := "1;\"a\nb\r\nc\";2018-09-17 20:38:00;x\n\n2;b\n3;c\"d;2018-09-17 20:40:00;z\n4;d\n"
+	mapping, err := NewColumnMapping(testLayout, "value=3", "")
+	require.Nil(t, err)
+	consumer := &recordConsumer{}
+	report, err := LoadDataWithReport(strings.NewReader(content), consumer, LoadDataOptions{
+		Delimiter:     ';',
+		NbFields:      -1,
+		Mapping:       mapping,
+		MaxErrorRatio: 1,
+	})
+	require.Nil(t, err)
+	assert.Len(t, consumer.records, 1)
+	assert.Equal(t, 4, report.Records)
+	require.Len(t, report.Errors, 3)
+	assert.Equal(t, 5, report.Errors[0].Line)
+	assert.Equal(t, 6, report.Errors[1].Line)
+	assert.Equal(t, 7, report.Errors[2].Line)
+}
+
+func TestLoadReportMaxLineErrors(t *testing.T) {
+	mapping, err := NewColumnMapping(testLayout, "value=3", "")
+	require.Nil(t, err)
+	content := strings.Repeat("1;a\n", MaxLineErrors+5)
+	report, err := LoadDataWithReport(strings.NewReader(content), &recordConsumer{}, LoadDataOptions{
+		Delimiter:     ';',
+		Mapping:       mapping,
+		MaxErrorRatio: 1,
+	})
+	require.Nil(t, err)
+	assert.Equal(t, MaxLineErrors+5, report.Skipped)
+	assert.Len(t, report.Errors, MaxLineErrors)
+}
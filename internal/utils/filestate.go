@@ -0,0 +1,137 @@
+package utils
+
+import (
+	"encoding/gob"
+	"io"
+	"sync"
+	"time"
+)
+
+// FileState keeps the state of the data of a module read from a file: the time of their last update, the version
+// of the loaded file, the options reading it and the report of its invalid records.
+// It is embedded in the context of the module, its zero value is ready to use.
+type FileState struct {
+	lastUpdate time.Time
+	version    FileVersion
+	options    *LoadDataOptions
+	report     *LoadReport
+	pushed     bool
+	mutex      sync.RWMutex
+}
+
+// GetLastUpdate returns the time of the last update of the data
+func (f *FileState) GetLastUpdate() time.Time {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+
+	return f.lastUpdate
+}
+
+// MarkUpdated marks the data as up to date when they are replaced
+func (f *FileState) MarkUpdated() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.lastUpdate = time.Now()
+	f.pushed = false
+}
+
+// MarkPushed records that the data have been replaced by a push rather than by the file, the unchanged file
+// does not mark them as up to date until it is loaded again
+func (f *FileState) MarkPushed() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.pushed = true
+}
+
+// MarkUnchanged marks the data as up to date when their file has not changed since its last load, nothing is
+// done if no file has been loaded yet or if the data have been pushed since
+func (f *FileState) MarkUnchanged() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.version.URI == "" || f.pushed {
+		return
+	}
+	f.lastUpdate = time.Now()
+}
+
+// GetFileVersion returns the version of the file of the loaded data
+func (f *FileState) GetFileVersion() FileVersion {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+
+	return f.version
+}
+
+// SetFileVersion keeps the version of the file of the loaded data
+func (f *FileState) SetFileVersion(version FileVersion) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.version = version
+}
+
+// SetLoadDataOptions changes the layout of the file, the file is loaded again at the next refresh
+func (f *FileState) SetLoadDataOptions(options LoadDataOptions) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.options = &options
+	f.version = FileVersion{}
+}
+
+// GetLoadDataOptions returns the options reading the file, the options of its default layout if not set
+func (f *FileState) GetLoadDataOptions(defaults LoadDataOptions) LoadDataOptions {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+
+	if f.options == nil {
+		return defaults
+	}
+	return *f.options
+}
+
+// GetLoadReport returns the report of the invalid records of the last file read, nil if none
+func (f *FileState) GetLoadReport() *LoadReport {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+
+	return f.report
+}
+
+// SetLoadReport keeps the report of the invalid records of the last file read
+func (f *FileState) SetLoadReport(report LoadReport) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.report = &report
+}
+
+// WriteSnapshot writes the data with the time of their last update
+func (f *FileState) WriteSnapshot(w io.Writer, data interface{}) error {
+	encoder := gob.NewEncoder(w)
+	if err := encoder.Encode(f.GetLastUpdate()); err != nil {
+		return err
+	}
+	return encoder.Encode(data)
+}
+
+// ReadSnapshot reads the data written by WriteSnapshot in data, a pointer, and restores the time of their
+// last update. The caller swaps the read data with the served ones.
+func (f *FileState) ReadSnapshot(r io.Reader, data interface{}) error {
+	var lastUpdate time.Time
+	decoder := gob.NewDecoder(r)
+	if err := decoder.Decode(&lastUpdate); err != nil {
+		return err
+	}
+	if err := decoder.Decode(data); err != nil {
+		return err
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.lastUpdate = lastUpdate
+	return nil
+}
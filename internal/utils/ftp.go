@@ -0,0 +1,108 @@
+package utils
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/jlaffaye/ftp"
+)
+
+// GetFileWithFtp downloads a file with FTP, or with FTPS for the scheme ftps (explicit TLS: the connection is
+// secured with AUTH TLS, the data connections too). The data connections are passive, with EPSV then PASV if
+// EPSV is refused. The parameters of the uri configure the connection:
+//   - epsv=false uses directly PASV, for the servers behind a NAT
+//   - insecure=true does not verify the certificate of a FTPS server
+//   - ca=/path/to/ca.pem verifies the certificate of a FTPS server with the certificate authorities of the file
+//
+// Without user in the uri, the anonymous login is used.
+func GetFileWithFtp(uri url.URL, connectionTimeout time.Duration) (io.ReadCloser, error) {
+	options, err := ftpOptions(uri, connectionTimeout)
+	if err != nil {
+		return nil, err
+	}
+	host := uri.Host
+	if uri.Port() == "" {
+		host = net.JoinHostPort(uri.Hostname(), "21")
+	}
+
+	client, err := ftp.Dial(host, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	user, password := "anonymous", "anonymous"
+	if uri.User != nil {
+		user = uri.User.Username()
+		password, _ = uri.User.Password()
+	}
+	if err = client.Login(user, password); err != nil {
+		client.Quit() // nolint: errcheck
+		return nil, err
+	}
+
+	response, err := client.Retr(uri.Path)
+	if err != nil {
+		client.Quit() // nolint: errcheck
+		return nil, err
+	}
+	if err = response.SetDeadline(time.Now().Add(10 * connectionTimeout)); err != nil {
+		response.Close()
+		client.Quit() // nolint: errcheck
+		return nil, err
+	}
+	return &ftpFile{Response: response, client: client}, nil
+}
+
+// ftpFile is a file being downloaded, the connection is closed with the file
+type ftpFile struct {
+	*ftp.Response
+	client *ftp.ServerConn
+}
+
+func (f *ftpFile) Close() error {
+	err := f.Response.Close()
+	if quitErr := f.client.Quit(); err == nil {
+		err = quitErr
+	}
+	return err
+}
+
+// ftpOptions returns the options of the connection given by the scheme and the parameters of the uri
+func ftpOptions(uri url.URL, connectionTimeout time.Duration) ([]ftp.DialOption, error) {
+	query := uri.Query()
+	options := []ftp.DialOption{ftp.DialWithTimeout(connectionTimeout)}
+	if query.Get("epsv") != "" {
+		epsv, err := boolParameter(query, "epsv")
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, ftp.DialWithDisabledEPSV(!epsv))
+	}
+	if uri.Scheme != "ftps" {
+		return options, nil
+	}
+
+	tlsConfig := &tls.Config{ServerName: uri.Hostname(), MinVersion: tls.VersionTLS12}
+	insecure, err := boolParameter(query, "insecure")
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig.InsecureSkipVerify = insecure // nolint: gosec
+	if path := query.Get("ca"); path != "" {
+		pem, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in %s", path)
+		}
+	}
+	return append(options, ftp.DialWithExplicitTLS(tlsConfig)), nil
+}
@@ -0,0 +1,272 @@
+package utils
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ftpServer is a minimal FTP server serving the files of a directory, with the passive mode (EPSV and PASV)
+// and the explicit TLS (AUTH TLS)
+type ftpServer struct {
+	dir      string
+	user     string
+	password string
+	tls      *tls.Config
+	listener net.Listener
+	commands []string
+	mutex    sync.Mutex
+}
+
+func newFtpServer(t *testing.T, dir string, tlsConfig *tls.Config) *ftpServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	server := &ftpServer{dir: dir, user: "forseti", password: "pass", tls: tlsConfig, listener: listener}
+	go server.serve()
+	return server
+}
+
+func (s *ftpServer) Close() {
+	s.listener.Close()
+}
+
+func (s *ftpServer) URI(scheme, path, query string) string {
+	return fmt.Sprintf("%s://%s:%s@%s%s?%s", scheme, s.user, s.password, s.listener.Addr(), path, query)
+}
+
+// Commands returns the commands received by the server, without their arguments
+func (s *ftpServer) Commands() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]string(nil), s.commands...)
+}
+
+func (s *ftpServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *ftpServer) handle(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	reply := func(format string, args ...interface{}) {
+		fmt.Fprintf(conn, format+"\r\n", args...)
+	}
+	var data net.Listener
+	defer func() {
+		if data != nil {
+			data.Close()
+		}
+	}()
+	secured := false
+
+	reply("220 forseti test server")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.SplitN(strings.TrimSpace(line), " ", 2)
+		command, argument := strings.ToUpper(fields[0]), ""
+		if len(fields) > 1 {
+			argument = fields[1]
+		}
+		s.mutex.Lock()
+		s.commands = append(s.commands, command)
+		s.mutex.Unlock()
+
+		switch command {
+		case "AUTH":
+			if s.tls == nil {
+				reply("502 TLS not supported")
+				continue
+			}
+			reply("234 AUTH TLS successful")
+			conn = tls.Server(conn, s.tls)
+			reader = bufio.NewReader(conn)
+		case "USER":
+			reply("331 password required")
+		case "PASS":
+			if argument != s.password {
+				reply("530 login incorrect")
+				continue
+			}
+			reply("230 logged in")
+		case "PROT":
+			secured = argument == "P"
+			reply("200 protection level set")
+		case "TYPE", "PBSZ", "OPTS":
+			reply("200 ok")
+		case "EPSV", "PASV":
+			if data == nil {
+				if data, err = net.Listen("tcp", "127.0.0.1:0"); err != nil {
+					reply("425 can not open data connection")
+					continue
+				}
+			}
+			port := data.Addr().(*net.TCPAddr).Port
+			if command == "EPSV" {
+				reply("229 Entering Extended Passive Mode (|||%d|)", port)
+			} else {
+				reply("227 Entering Passive Mode (127,0,0,1,%d,%d)", port/256, port%256)
+			}
+		case "RETR":
+			content, err := ioutil.ReadFile(filepath.Join(s.dir, filepath.Clean("/"+argument)))
+			if err != nil || data == nil {
+				reply("550 file not found")
+				continue
+			}
+			dataConn, err := data.Accept()
+			if err != nil {
+				reply("425 can not open data connection")
+				continue
+			}
+			if secured {
+				dataConn = tls.Server(dataConn, s.tls)
+			}
+			reply("150 opening data connection")
+			_, _ = dataConn.Write(content)
+			dataConn.Close()
+			reply("226 transfer complete")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 command not implemented")
+		}
+	}
+}
+
+// newCertificate returns a self-signed certificate for 127.0.0.1, and its PEM encoding
+func newCertificate(t *testing.T) (tls.Certificate, []byte) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.Nil(t, err)
+	template := x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "forseti"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	require.Nil(t, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key},
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func TestGetFTPFile(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	server := newFtpServer(t, fixtureDir, nil)
+	defer server.Close()
+
+	uri, err := url.Parse(server.URI("ftp", "/oneline.txt", ""))
+	require.Nil(err)
+	reader, err := GetFile(*uri, defaultTimeout)
+	require.Nil(err)
+	b, err := ioutil.ReadAll(reader)
+	require.Nil(err)
+	assert.Equal(oneline, string(b))
+	assert.Contains(server.Commands(), "EPSV")
+	assert.NotContains(server.Commands(), "PASV")
+
+	// epsv=false uses PASV
+	uri, err = url.Parse(server.URI("ftp", "/oneline.txt", "epsv=false"))
+	require.Nil(err)
+	reader, err = GetFile(*uri, defaultTimeout)
+	require.Nil(err)
+	b, err = ioutil.ReadAll(reader)
+	require.Nil(err)
+	assert.Equal(oneline, string(b))
+	assert.Contains(server.Commands(), "PASV")
+
+	uri, err = url.Parse(server.URI("ftp", "/not.txt", ""))
+	require.Nil(err)
+	_, err = GetFile(*uri, defaultTimeout)
+	assert.Error(err)
+
+	uri, err = url.Parse(strings.Replace(server.URI("ftp", "/oneline.txt", ""), ":pass@", ":wrongpass@", 1))
+	require.Nil(err)
+	_, err = GetFile(*uri, defaultTimeout)
+	assert.Error(err)
+
+	// a plain FTP server refuses FTPS
+	uri, err = url.Parse(server.URI("ftps", "/oneline.txt", "insecure=true"))
+	require.Nil(err)
+	_, err = GetFile(*uri, defaultTimeout)
+	assert.Error(err)
+}
+
+func TestGetFTPSFile(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	certificate, certificatePEM := newCertificate(t)
+	server := newFtpServer(t, fixtureDir, &tls.Config{Certificates: []tls.Certificate{certificate}})
+	defer server.Close()
+
+	caFile, err := ioutil.TempFile("", "ca")
+	require.Nil(err)
+	defer os.Remove(caFile.Name())
+	_, err = caFile.Write(certificatePEM)
+	require.Nil(err)
+	require.Nil(caFile.Close())
+
+	// the certificate of the server is verified with the certificate authorities of the parameter ca
+	uri, err := url.Parse(server.URI("ftps", "/oneline.txt", "ca="+caFile.Name()))
+	require.Nil(err)
+	reader, err := GetFile(*uri, defaultTimeout)
+	require.Nil(err)
+	b, err := ioutil.ReadAll(reader)
+	require.Nil(err)
+	assert.Equal(oneline, string(b))
+	assert.Contains(server.Commands(), "AUTH")
+	assert.Contains(server.Commands(), "PROT")
+
+	uri, err = url.Parse(server.URI("ftps", "/oneline.txt", "insecure=true&epsv=false"))
+	require.Nil(err)
+	reader, err = GetFile(*uri, defaultTimeout)
+	require.Nil(err)
+	b, err = ioutil.ReadAll(reader)
+	require.Nil(err)
+	assert.Equal(oneline, string(b))
+
+	// a self-signed certificate is refused by default
+	uri, err = url.Parse(server.URI("ftps", "/oneline.txt", ""))
+	require.Nil(err)
+	_, err = GetFile(*uri, defaultTimeout)
+	assert.Error(err)
+
+	uri, err = url.Parse(server.URI("ftps", "/oneline.txt", "insecure=maybe"))
+	require.Nil(err)
+	_, err = GetFile(*uri, defaultTimeout)
+	assert.EqualError(err, "invalid parameter insecure: strconv.ParseBool: parsing \"maybe\": invalid syntax")
+}
@@ -0,0 +1,123 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/sftp"
+)
+
+// errNoMatch is returned by selectFile when no file matches the pattern
+var errNoMatch = errors.New("no file matches the pattern")
+
+// hasGlob returns true if the path contains a glob pattern
+func hasGlob(filePath string) bool {
+	return strings.ContainsAny(filePath, "*?[")
+}
+
+// selectFile returns the path of the file matching the glob pattern of the name of the file of the uri,
+// /path/to/extract_*.txt, read with file or sftp. The newest file is selected by its modification time, or by
+// its name with the parameter order=name of the uri (the last one in the lexicographic order).
+func selectFile(uri url.URL, connectionTimeout time.Duration) (string, error) {
+	dir, pattern := path.Split(uri.Path)
+	if hasGlob(dir) {
+		return "", fmt.Errorf("invalid path %s: the glob pattern must be in the name of the file", uri.Path)
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return "", fmt.Errorf("invalid pattern %s: %s", pattern, err)
+	}
+	byName := false
+	switch order := uri.Query().Get("order"); order {
+	case "", "mtime":
+	case "name":
+		byName = true
+	default:
+		return "", fmt.Errorf("invalid parameter order: %s, mtime or name expected", order)
+	}
+
+	var files []os.FileInfo
+	var err error
+	switch uri.Scheme {
+	case "file":
+		files, err = ioutil.ReadDir(dir)
+	case "sftp":
+		err = withSftpClient(uri, connectionTimeout, func(client *sftp.Client) (err error) {
+			files, err = client.ReadDir(dir)
+			return err
+		})
+	default:
+		return "", fmt.Errorf("the glob patterns are not supported with %s", uri.Scheme)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	var matches []os.FileInfo
+	for _, file := range files {
+		if matched, _ := path.Match(pattern, file.Name()); matched && !file.IsDir() {
+			matches = append(matches, file)
+		}
+	}
+	if len(matches) == 0 {
+		return "", errNoMatch
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if !byName && !matches[i].ModTime().Equal(matches[j].ModTime()) {
+			return matches[i].ModTime().Before(matches[j].ModTime())
+		}
+		return matches[i].Name() < matches[j].Name()
+	})
+	return path.Join(dir, matches[len(matches)-1].Name()), nil
+}
+
+// processedAction returns the action on the file selected by a glob pattern once loaded, given by the parameters
+// of the uri:
+//   - archive=/path/to/dir moves the file in the directory
+//   - delete=true deletes the file
+//
+// The actions are supported with file and sftp, the query of the other uri belongs to their server and is left
+// untouched. They are not applied to a file given by its name, read again at each refresh (as the references of
+// Oditi). The returned function is nil without action.
+func processedAction(uri url.URL, connectionTimeout time.Duration) (func() error, error) {
+	if uri.Scheme != "file" && uri.Scheme != "sftp" {
+		return nil, nil
+	}
+	query := uri.Query()
+	archive := query.Get("archive")
+	remove, err := boolParameter(query, "delete")
+	if err != nil {
+		return nil, err
+	}
+	if archive == "" && !remove {
+		return nil, nil
+	}
+	if archive != "" && remove {
+		return nil, fmt.Errorf("the parameters archive and delete can not be used together")
+	}
+
+	filePath := uri.Path
+	archived := path.Join(archive, path.Base(filePath))
+	if uri.Scheme == "file" {
+		return func() error {
+			if remove {
+				return os.Remove(filePath)
+			}
+			return os.Rename(filePath, archived)
+		}, nil
+	}
+	return func() error {
+		return withSftpClient(uri, connectionTimeout, func(client *sftp.Client) error {
+			if remove {
+				return client.Remove(filePath)
+			}
+			return client.Rename(filePath, archived)
+		})
+	}, nil
+}
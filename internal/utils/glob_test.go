@@ -0,0 +1,180 @@
+package utils
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeDrop writes the files of a drop directory, the files are modified one minute apart in the given order
+func writeDrop(t *testing.T, dir string, names ...string) {
+	modTime := time.Now().Add(-time.Hour)
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		require.Nil(t, ioutil.WriteFile(path, []byte(name), 0600))
+		require.Nil(t, os.Chtimes(path, modTime, modTime))
+		modTime = modTime.Add(time.Minute)
+	}
+}
+
+func loadFile(t *testing.T, rawURI string, version FileVersion) (string, FileVersion, error) {
+	uri, err := url.Parse(rawURI)
+	require.Nil(t, err)
+	file, err := GetFileIfModified(*uri, defaultTimeout, version)
+	if err != nil {
+		return "", version, err
+	}
+	defer file.Close()
+	content, err := ioutil.ReadAll(file)
+	require.Nil(t, err)
+	require.Nil(t, file.Done())
+	return string(content), file.Version(), nil
+}
+
+func TestGlobSelectsNewestFile(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "drop")
+	require.Nil(err)
+	defer os.RemoveAll(dir)
+	writeDrop(t, dir, "extract_20261017_0930.txt", "extract_20261017_0900.txt", "other_20261017_1000.txt")
+
+	content, version, err := loadFile(t, "file://"+dir+"/extract_*.txt", FileVersion{})
+	require.Nil(err)
+	assert.Equal("extract_20261017_0900.txt", content)
+	assert.Equal(filepath.Join(dir, "extract_20261017_0900.txt"), version.Path)
+	_, _, err = loadFile(t, "file://"+dir+"/extract_*.txt", version)
+	assert.Equal(ErrNotModified, err)
+
+	content, _, err = loadFile(t, "file://"+dir+"/extract_*.txt?order=name", FileVersion{})
+	require.Nil(err)
+	assert.Equal("extract_20261017_0930.txt", content)
+
+	// a new file is read even with the same modification time and size, the files are then ordered by name
+	writeDrop(t, dir, "extract_20261017_0901.txt")
+	modTime := version.ModTime
+	require.Nil(os.Chtimes(filepath.Join(dir, "extract_20261017_0901.txt"), modTime, modTime))
+	content, _, err = loadFile(t, "file://"+dir+"/extract_*.txt", version)
+	require.Nil(err)
+	assert.Equal("extract_20261017_0901.txt", content)
+
+	// no file has been dropped yet
+	_, _, err = loadFile(t, "file://"+dir+"/none_*.txt", FileVersion{})
+	assert.Equal(ErrNotModified, err)
+	_, _, err = loadFile(t, "file://"+dir+"/extract_*.txt?order=size", FileVersion{})
+	assert.EqualError(err, dir+"/extract_*.txt: invalid parameter order: size, mtime or name expected")
+	_, _, err = loadFile(t, "file://"+dir+"/*/extract.txt", FileVersion{})
+	assert.Error(err)
+	_, _, err = loadFile(t, "https://example.com/extract_*.txt", FileVersion{})
+	assert.EqualError(err, "/extract_*.txt: the glob patterns are not supported with https")
+}
+
+func TestArchiveAndDeleteFiles(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "drop")
+	require.Nil(err)
+	defer os.RemoveAll(dir)
+	archive := filepath.Join(dir, "archive")
+	require.Nil(os.Mkdir(archive, 0700))
+	writeDrop(t, dir, "extract_0900.txt", "extract_0930.txt")
+
+	// the newest file is archived, the next refresh reads the other one
+	uri := "file://" + dir + "/extract_*.txt?archive=" + archive
+	content, version, err := loadFile(t, uri, FileVersion{})
+	require.Nil(err)
+	assert.Equal("extract_0930.txt", content)
+	assert.FileExists(filepath.Join(archive, "extract_0930.txt"))
+	content, version, err = loadFile(t, uri, version)
+	require.Nil(err)
+	assert.Equal("extract_0900.txt", content)
+
+	// once every file is archived, the last one is kept
+	_, _, err = loadFile(t, uri, version)
+	assert.Equal(ErrNotModified, err)
+	_, _, err = loadFile(t, uri, FileVersion{})
+	assert.Equal(ErrNotModified, err)
+
+	writeDrop(t, dir, "extract_1000.txt")
+	content, _, err = loadFile(t, "file://"+dir+"/extract_*.txt?delete=true", FileVersion{})
+	require.Nil(err)
+	assert.Equal("extract_1000.txt", content)
+	_, err = os.Stat(filepath.Join(dir, "extract_1000.txt"))
+	assert.True(os.IsNotExist(err))
+
+	// a file given by its name is neither archived nor deleted, it is read again at the next refresh
+	writeDrop(t, dir, "extract_1030.txt")
+	named := "file://" + dir + "/extract_1030.txt?delete=true"
+	content, version, err = loadFile(t, named, FileVersion{})
+	require.Nil(err)
+	assert.Equal("extract_1030.txt", content)
+	assert.FileExists(filepath.Join(dir, "extract_1030.txt"))
+	_, _, err = loadFile(t, named, version)
+	assert.Equal(ErrNotModified, err)
+	content, _, err = loadFile(t, named, FileVersion{})
+	require.Nil(err)
+	assert.Equal("extract_1030.txt", content)
+	require.Nil(os.Remove(filepath.Join(dir, "extract_1030.txt")))
+
+	writeDrop(t, dir, "extract_1100.txt")
+	_, _, err = loadFile(t, uri+"&delete=true", FileVersion{})
+	assert.EqualError(err, "the parameters archive and delete can not be used together")
+}
+
+func TestArchiveParametersOfHTTPServer(t *testing.T) {
+	// the parameters archive and delete of an http uri belong to the server
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.URL.RawQuery))
+	}))
+	defer server.Close()
+
+	content, _, err := loadFile(t, server.URL+"/extract.txt?delete=x&archive=y", FileVersion{})
+	require.Nil(t, err)
+	assert.Equal(t, "delete=x&archive=y", content)
+}
+
+func TestSFTPGlobAndArchive(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	server := newSftpServer(t)
+	defer server.Close()
+	defer CloseSftpConnections()
+
+	dir, err := ioutil.TempDir("", "drop")
+	require.Nil(err)
+	defer os.RemoveAll(dir)
+	archive := filepath.Join(dir, "archive")
+	require.Nil(os.Mkdir(archive, 0700))
+	writeDrop(t, dir, "extract_0930.txt", "extract_0900.txt")
+
+	uri := server.URI("forseti:pass", dir+"/extract_*.txt", "insecure=true&archive="+archive)
+	content, version, err := loadFile(t, uri, FileVersion{})
+	require.Nil(err)
+	assert.Equal("extract_0900.txt", content)
+	assert.FileExists(filepath.Join(archive, "extract_0900.txt"))
+
+	content, version, err = loadFile(t, uri, version)
+	require.Nil(err)
+	assert.Equal("extract_0930.txt", content)
+	_, _, err = loadFile(t, uri, version)
+	assert.Equal(ErrNotModified, err)
+
+	writeDrop(t, dir, "extract_1000.txt")
+	content, _, err = loadFile(t, server.URI("forseti:pass", dir+"/extract_*.txt", "insecure=true&delete=true"),
+		FileVersion{})
+	require.Nil(err)
+	assert.Equal("extract_1000.txt", content)
+	_, err = os.Stat(filepath.Join(dir, "extract_1000.txt"))
+	assert.True(os.IsNotExist(err))
+}
@@ -0,0 +1,132 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// emptySHA256 is the SHA-256 checksum of an empty payload
+const emptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// GetFileWithS3 downloads an object of a S3 compatible storage (AWS S3, MinIO), s3://bucket/path/to/key. The
+// credentials are the user and the password of the uri, s3://access_key:secret_key@bucket/key (the secret key is
+// url encoded), else the environment variables AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN.
+// Without credentials, the object is downloaded anonymously. The parameters of the uri configure the storage:
+//   - endpoint=http://minio:9000 the url of the storage, $AWS_ENDPOINT_URL or https://s3.<region>.amazonaws.com
+//     by default
+//   - region=eu-west-3 the region of the bucket, $AWS_REGION or us-east-1 by default
+//
+// The requests are path-style (<endpoint>/<bucket>/<key>) and conditional, as with http(s).
+func GetFileWithS3(uri url.URL, connectionTimeout time.Duration, version FileVersion) (
+	io.ReadCloser, FileVersion, error) {
+	query := uri.Query()
+	region := firstNonEmpty(query.Get("region"), os.Getenv("AWS_REGION"), "us-east-1")
+	endpoint, err := url.Parse(firstNonEmpty(query.Get("endpoint"), os.Getenv("AWS_ENDPOINT_URL"),
+		fmt.Sprintf("https://s3.%s.amazonaws.com", region)))
+	if err != nil {
+		return nil, version, fmt.Errorf("invalid parameter endpoint: %s", err)
+	}
+	if uri.Host == "" || strings.Trim(uri.Path, "/") == "" {
+		return nil, version, fmt.Errorf("invalid uri %s://%s%s: s3://bucket/key expected", uri.Scheme, uri.Host,
+			uri.Path)
+	}
+
+	objectPath := strings.TrimSuffix(endpoint.Path, "/") + "/" + uri.Host + uri.Path
+	object := url.URL{Scheme: endpoint.Scheme, Host: endpoint.Host, Path: objectPath, RawPath: s3Escape(objectPath)}
+	req, err := http.NewRequest("GET", object.String(), nil)
+	if err != nil {
+		return nil, version, err
+	}
+
+	accessKey, secretKey, sessionToken := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		os.Getenv("AWS_SESSION_TOKEN")
+	if uri.User != nil {
+		accessKey, sessionToken = uri.User.Username(), ""
+		secretKey, _ = uri.User.Password()
+	}
+	if accessKey != "" {
+		signS3Request(req, accessKey, secretKey, sessionToken, region, time.Now())
+	}
+	return getIfModified(req, uri, connectionTimeout, version)
+}
+
+// signS3Request signs the request with the AWS signature version 4, the payload of the request is empty
+func signS3Request(req *http.Request, accessKey, secretKey, sessionToken, region string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", emptySHA256)
+	if sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", sessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		if strings.HasPrefix(name, "x-amz-") || name == "range" {
+			headers[name] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&canonicalHeaders, "%s:%s\n", name, headers[name])
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{req.Method, req.URL.EscapedPath(), req.URL.RawQuery,
+		canonicalHeaders.String(), signedHeaders, emptySHA256}, "\n")
+	scope := date + "/" + region + "/s3/aws4_request"
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	key := []byte("AWS4" + secretKey)
+	for _, part := range []string{date, region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3Escape encodes the path as expected by the signature: every byte but the unreserved characters and the
+// slashes is encoded
+func s3Escape(path string) string {
+	var escaped strings.Builder
+	for _, b := range []byte(path) {
+		if 'A' <= b && b <= 'Z' || 'a' <= b && b <= 'z' || '0' <= b && b <= '9' || strings.IndexByte("-._~/", b) >= 0 {
+			escaped.WriteByte(b)
+		} else {
+			fmt.Fprintf(&escaped, "%%%02X", b)
+		}
+	}
+	return escaped.String()
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,394 @@
+package utils
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/sftp"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// GetFileWithSftp downloads a file with SFTP. The user authenticates with the password of the uri and with the
+// keys given by the parameters of the uri:
+//   - key=/path/to/id_rsa authenticates with a private key, passphrase=... decrypts it
+//   - agent=true authenticates with the keys of the ssh-agent of $SSH_AUTH_SOCK
+//
+// The key of the host is verified with:
+//   - fingerprint=SHA256:... the fingerprint of the key, as given by ssh-keygen -l (MD5:... is accepted too)
+//   - known_hosts=/path/to/known_hosts a known_hosts file, ~/.ssh/known_hosts is used by default if it exists
+//   - insecure=true disables the verification of the key of the host
+//
+// The connection is kept open for the next downloads from the host, it is opened again if it has been lost.
+func GetFileWithSftp(uri url.URL, connectionTimeout time.Duration) (io.ReadCloser, error) {
+	var file *sftpFile
+	err := useSftpClient(uri, connectionTimeout, func(client *sftpClient) error {
+		opened, err := client.sftp.Open(uri.Path)
+		if err != nil {
+			return err
+		}
+		// the connection is in use until the file is closed
+		sftpClients.acquire(client)
+		file = &sftpFile{Reader: bufio.NewReaderSize(opened, sftpBufferSize), file: opened, client: client}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// sftpBufferSize is the size of the reads of the SFTP files, split in concurrent requests
+const sftpBufferSize = 1 << 20
+
+// sftpFile reads a SFTP file by large blocks, the connection is kept open once the file is closed
+type sftpFile struct {
+	*bufio.Reader
+	file   *sftp.File
+	client *sftpClient
+	once   sync.Once
+}
+
+func (f *sftpFile) Close() error {
+	f.once.Do(func() { sftpClients.release(f.client) })
+	return f.file.Close()
+}
+
+// StatSftpFile returns the description of a file of a SFTP host, with its modification time and its size
+func StatSftpFile(uri url.URL, connectionTimeout time.Duration) (os.FileInfo, error) {
+	var info os.FileInfo
+	err := withSftpClient(uri, connectionTimeout, func(client *sftp.Client) (err error) {
+		info, err = client.Stat(uri.Path)
+		return err
+	})
+	return info, err
+}
+
+// withSftpClient calls fn with the SFTP connection to the host of the uri, fn is called again on a new
+// connection if the connection kept open has been lost
+func withSftpClient(uri url.URL, connectionTimeout time.Duration, fn func(*sftp.Client) error) error {
+	return useSftpClient(uri, connectionTimeout, func(client *sftpClient) error {
+		return fn(client.sftp)
+	})
+}
+
+// useSftpClient is withSftpClient, the connection is in use while fn is called
+func useSftpClient(uri url.URL, connectionTimeout time.Duration, fn func(*sftpClient) error) error {
+	client, err := sftpClients.get(uri, connectionTimeout)
+	if err != nil {
+		return err
+	}
+	err = fn(client)
+	sftpClients.release(client)
+	if err != nil && isConnectionError(err) {
+		// the connection kept open may have been closed by the host since the last download
+		logrus.Infof("SFTP connection to %s lost, reconnecting: %s", uri.Host, err)
+		sftpClients.remove(client)
+		if client, err = sftpClients.get(uri, connectionTimeout); err != nil {
+			return err
+		}
+		err = fn(client)
+		sftpClients.release(client)
+	}
+	return err
+}
+
+// CloseSftpConnections closes the SFTP connections kept open between the downloads
+func CloseSftpConnections() {
+	sftpClients.closeAll()
+}
+
+// isConnectionError returns false for the errors returned by the SFTP host on a working connection
+func isConnectionError(err error) bool {
+	if _, ok := err.(*sftp.StatusError); ok {
+		return false
+	}
+	return !os.IsNotExist(err) && !os.IsPermission(err)
+}
+
+// sftpKeepAlive is the interval of the keep-alive requests sent on the SFTP connections, sftpIdleTimeout the
+// duration after which an unused connection is closed
+var (
+	sftpKeepAlive   = 30 * time.Second
+	sftpIdleTimeout = 10 * time.Minute
+)
+
+// sftpClients are the SFTP connections kept open, one by host and credentials
+var sftpClients = &sftpPool{clients: make(map[string]*sftpClient)}
+
+type sftpClient struct {
+	key      string
+	ssh      *ssh.Client
+	sftp     *sftp.Client
+	lastUsed time.Time
+	inUse    int // the number of calls and of open files using the connection, it is not idle while in use
+}
+
+type sftpPool struct {
+	clients map[string]*sftpClient
+	mutex   sync.Mutex
+}
+
+// get returns the SFTP connection to the host of the uri, opened if there is none. The connection is in use until
+// it is released.
+func (p *sftpPool) get(uri url.URL, connectionTimeout time.Duration) (*sftpClient, error) {
+	key := sftpKey(uri)
+	p.mutex.Lock()
+	client, ok := p.clients[key]
+	if ok {
+		client.inUse++
+	}
+	p.mutex.Unlock()
+	if ok {
+		return client, nil
+	}
+
+	client, err := dialSftp(uri, connectionTimeout)
+	if err != nil {
+		return nil, err
+	}
+	client.key = key
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if concurrent, ok := p.clients[key]; ok {
+		// another download has opened a connection in the meantime
+		client.ssh.Close()
+		concurrent.inUse++
+		return concurrent, nil
+	}
+	client.inUse++
+	p.clients[key] = client
+	go p.keepAlive(client, sftpKeepAlive, sftpIdleTimeout)
+	return client, nil
+}
+
+// acquire marks the connection as in use again, until it is released
+func (p *sftpPool) acquire(client *sftpClient) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	client.inUse++
+}
+
+// release marks the end of a use of the connection, it is idle from then if it is not used anymore
+func (p *sftpPool) release(client *sftpClient) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	client.inUse--
+	client.lastUsed = time.Now()
+}
+
+// remove closes the connection and removes it from the pool
+func (p *sftpPool) remove(client *sftpClient) {
+	p.mutex.Lock()
+	if p.clients[client.key] == client {
+		delete(p.clients, client.key)
+	}
+	p.mutex.Unlock()
+	// the SFTP session ends with the ssh connection
+	client.ssh.Close()
+}
+
+func (p *sftpPool) closeAll() {
+	p.mutex.Lock()
+	clients := make([]*sftpClient, 0, len(p.clients))
+	for _, client := range p.clients {
+		clients = append(clients, client)
+	}
+	p.mutex.Unlock()
+	for _, client := range clients {
+		p.remove(client)
+	}
+}
+
+// keepAlive sends the keep-alive requests on the connection until it is closed, by the host or once idle: not
+// in use since idleTimeout
+func (p *sftpPool) keepAlive(client *sftpClient, interval, idleTimeout time.Duration) {
+	closed := make(chan struct{})
+	go func() {
+		_ = client.ssh.Wait()
+		close(closed)
+	}()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-closed:
+			p.remove(client)
+			return
+		case <-ticker.C:
+			p.mutex.Lock()
+			idle := client.inUse == 0 && time.Since(client.lastUsed) > idleTimeout
+			p.mutex.Unlock()
+			if idle {
+				logrus.Debugf("closing the idle SFTP connection to %s", client.ssh.RemoteAddr())
+				p.remove(client)
+				return
+			}
+			if _, _, err := client.ssh.SendRequest("keepalive@openssh.com", true, nil); err != nil {
+				p.remove(client)
+				return
+			}
+		}
+	}
+}
+
+// sftpKey identifies the connections to a host with the same credentials and parameters
+func sftpKey(uri url.URL) string {
+	uri.Path, uri.RawPath, uri.Fragment = "", "", ""
+	return uri.String()
+}
+
+func dialSftp(uri url.URL, connectionTimeout time.Duration) (*sftpClient, error) {
+	sshConfig, closeAgent, err := sshClientConfig(uri, connectionTimeout)
+	if err != nil {
+		return nil, err
+	}
+	sshClient, err := ssh.Dial("tcp", sshAddress(uri), sshConfig)
+	closeAgent()
+	if err != nil {
+		return nil, err
+	}
+
+	// open an SFTP session over an existing ssh connection.
+	client, err := sftp.NewClient(sshClient)
+	if err != nil {
+		sshClient.Close()
+		return nil, err
+	}
+	return &sftpClient{ssh: sshClient, sftp: client}, nil
+}
+
+// sshAddress returns the address of the host of the uri, with the default port of ssh
+func sshAddress(uri url.URL) string {
+	if uri.Port() == "" {
+		return net.JoinHostPort(uri.Hostname(), "22")
+	}
+	return uri.Host
+}
+
+// sshClientConfig returns the configuration of the ssh connection given by the uri. The returned function closes
+// the connection to the ssh-agent, it must be called once connected.
+func sshClientConfig(uri url.URL, connectionTimeout time.Duration) (*ssh.ClientConfig, func(), error) {
+	query := uri.Query()
+	closeAgent := func() {}
+	var auth []ssh.AuthMethod
+	if password, ok := uri.User.Password(); ok {
+		auth = append(auth, ssh.Password(password))
+	}
+	if path := query.Get("key"); path != "" {
+		signer, err := readPrivateKey(path, query.Get("passphrase"))
+		if err != nil {
+			return nil, closeAgent, err
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+	useAgent, err := boolParameter(query, "agent")
+	if err != nil {
+		return nil, closeAgent, err
+	}
+	if useAgent {
+		conn, err := net.Dial("unix", os.Getenv("SSH_AUTH_SOCK"))
+		if err != nil {
+			return nil, closeAgent, fmt.Errorf("impossible to connect to the ssh-agent: %s", err)
+		}
+		closeAgent = func() { conn.Close() }
+		auth = append(auth, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+	}
+
+	hostKeyCallback, err := hostKeyCallback(query)
+	if err != nil {
+		closeAgent()
+		return nil, func() {}, err
+	}
+	return &ssh.ClientConfig{
+		User:            uri.User.Username(),
+		Auth:            auth,
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         connectionTimeout,
+	}, closeAgent, nil
+}
+
+// readPrivateKey reads a private key in the PEM format, encrypted if the passphrase is given
+func readPrivateKey(path, passphrase string) (ssh.Signer, error) {
+	pem, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if passphrase != "" {
+		return ssh.ParsePrivateKeyWithPassphrase(pem, []byte(passphrase))
+	}
+	return ssh.ParsePrivateKey(pem)
+}
+
+// hostKeyCallback returns the verification of the key of the host given by the parameters of the uri
+func hostKeyCallback(query url.Values) (ssh.HostKeyCallback, error) {
+	if fingerprint := query.Get("fingerprint"); fingerprint != "" {
+		// a + not encoded in the uri is decoded as a space
+		return fingerprintCallback(strings.Replace(fingerprint, " ", "+", -1)), nil
+	}
+	if path := query.Get("known_hosts"); path != "" {
+		return knownhosts.New(path)
+	}
+	insecure, err := boolParameter(query, "insecure")
+	if err != nil {
+		return nil, err
+	}
+	if insecure {
+		logrus.Warn("the key of the SFTP host is not verified")
+		return ssh.InsecureIgnoreHostKey(), nil //nolint:gosec
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		if path := filepath.Join(home, ".ssh", "known_hosts"); fileExists(path) {
+			return knownhosts.New(path)
+		}
+	}
+	return nil, fmt.Errorf("the key of the host can not be verified: add it to ~/.ssh/known_hosts (ssh-keyscan), " +
+		"set the parameter fingerprint or known_hosts of the uri, or insecure=true to disable the verification")
+}
+
+// fingerprintCallback accepts only the key of the host with the fingerprint
+func fingerprintCallback(fingerprint string) ssh.HostKeyCallback {
+	md5 := strings.HasPrefix(fingerprint, "MD5:")
+	fingerprint = strings.TrimPrefix(fingerprint, "MD5:")
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		actual := ssh.FingerprintSHA256(key)
+		if md5 {
+			actual = ssh.FingerprintLegacyMD5(key)
+		}
+		if subtle.ConstantTimeCompare([]byte(fingerprint), []byte(actual)) != 1 {
+			return fmt.Errorf("the fingerprint of the key of %s is %s, %s expected", hostname, actual, fingerprint)
+		}
+		return nil
+	}
+}
+
+// boolParameter returns the value of a boolean parameter of the uri, false if not set
+func boolParameter(query url.Values, name string) (bool, error) {
+	value := query.Get(name)
+	if value == "" {
+		return false, nil
+	}
+	result, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid parameter %s: %s", name, err)
+	}
+	return result, nil
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,467 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/sftp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// sftpServer is a minimal SFTP server serving the files of the filesystem, the user authenticates with a password
+// or with one of the authorized keys
+type sftpServer struct {
+	listener   net.Listener
+	config     *ssh.ServerConfig
+	hostKey    ssh.Signer
+	authorized [][]byte
+	conns      []net.Conn
+	mutex      sync.Mutex
+}
+
+func newSftpServer(t *testing.T, authorized ...ssh.PublicKey) *sftpServer {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.Nil(t, err)
+	hostKey, err := ssh.NewSignerFromKey(key)
+	require.Nil(t, err)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+
+	server := &sftpServer{listener: listener, hostKey: hostKey}
+	for _, key := range authorized {
+		server.authorized = append(server.authorized, key.Marshal())
+	}
+	server.config = &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if conn.User() == "forseti" && string(password) == "pass" {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("password rejected for %s", conn.User())
+		},
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			for _, authorized := range server.authorized {
+				if bytes.Equal(authorized, key.Marshal()) {
+					return nil, nil
+				}
+			}
+			return nil, fmt.Errorf("unknown key for %s", conn.User())
+		},
+	}
+	server.config.AddHostKey(hostKey)
+	go server.serve()
+	return server
+}
+
+func (s *sftpServer) Close() {
+	s.listener.Close()
+	s.Disconnect()
+}
+
+// Disconnect closes the connections opened on the server
+func (s *sftpServer) Disconnect() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+	s.conns = nil
+}
+
+// Connections returns the number of connections opened on the server
+func (s *sftpServer) Connections() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.conns)
+}
+
+func (s *sftpServer) URI(user, path, query string) string {
+	return fmt.Sprintf("sftp://%s@%s%s?%s", user, s.listener.Addr(), path, query)
+}
+
+func (s *sftpServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mutex.Lock()
+		s.conns = append(s.conns, conn)
+		s.mutex.Unlock()
+		go s.handle(conn)
+	}
+}
+
+func (s *sftpServer) handle(conn net.Conn) {
+	defer conn.Close()
+	_, channels, requests, err := ssh.NewServerConn(conn, s.config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(requests)
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "session" {
+			_ = newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			for request := range requests {
+				ok := request.Type == "subsystem" && string(request.Payload[4:]) == "sftp"
+				_ = request.Reply(ok, nil)
+			}
+		}()
+		go func() {
+			defer channel.Close()
+			server, err := sftp.NewServer(channel)
+			if err != nil {
+				return
+			}
+			_ = server.Serve()
+		}()
+	}
+}
+
+// writePrivateKey writes the private key in the PEM format, encrypted with the passphrase if it is given
+func writePrivateKey(t *testing.T, key interface{}, passphrase string) string {
+	var block *pem.Block
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		block = &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(k)}
+	default:
+		der, err := x509.MarshalPKCS8PrivateKey(k)
+		require.Nil(t, err)
+		block = &pem.Block{Type: "PRIVATE KEY", Bytes: der}
+	}
+	if passphrase != "" {
+		var err error
+		// nolint: staticcheck
+		block, err = x509.EncryptPEMBlock(rand.Reader, block.Type, block.Bytes, []byte(passphrase),
+			x509.PEMCipherAES256)
+		require.Nil(t, err)
+	}
+	file, err := ioutil.TempFile("", "id")
+	require.Nil(t, err)
+	defer file.Close()
+	require.Nil(t, pem.Encode(file, block))
+	return file.Name()
+}
+
+func readOneline(t *testing.T, rawURI string) {
+	uri, err := url.Parse(rawURI)
+	require.Nil(t, err)
+	reader, err := GetFile(*uri, defaultTimeout)
+	require.Nil(t, err)
+	defer reader.Close()
+	b, err := ioutil.ReadAll(reader)
+	require.Nil(t, err)
+	assert.Equal(t, oneline, string(b))
+}
+
+func TestGetSFTPFileWithPasswordAndFingerprint(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	server := newSftpServer(t)
+	defer server.Close()
+	path := filepath.Join(fixtureDir, "oneline.txt")
+
+	hostKey := server.hostKey.PublicKey()
+	fingerprint := url.QueryEscape(ssh.FingerprintSHA256(hostKey))
+	readOneline(t, server.URI("forseti:pass", path, "fingerprint="+fingerprint))
+	// a + not encoded is accepted too
+	readOneline(t, server.URI("forseti:pass", path, "fingerprint="+ssh.FingerprintSHA256(hostKey)))
+	readOneline(t, server.URI("forseti:pass", path, "fingerprint=MD5:"+ssh.FingerprintLegacyMD5(hostKey)))
+	readOneline(t, server.URI("forseti:pass", path, "insecure=true"))
+
+	uri, err := url.Parse(server.URI("forseti:wrongpass", path, "fingerprint="+fingerprint))
+	require.Nil(err)
+	_, err = GetFile(*uri, defaultTimeout)
+	assert.Error(err)
+
+	// the connection is refused with another key of the host
+	uri, err = url.Parse(server.URI("forseti:pass", path, "fingerprint=SHA256%3Aunknown"))
+	require.Nil(err)
+	_, err = GetFile(*uri, defaultTimeout)
+	require.Error(err)
+	assert.Contains(err.Error(), "SHA256:unknown expected")
+
+	uri, err = url.Parse(server.URI("forseti:pass", filepath.Join(fixtureDir, "not.txt"), "insecure=true"))
+	require.Nil(err)
+	_, err = GetFile(*uri, defaultTimeout)
+	assert.Error(err)
+}
+
+func TestGetSFTPFileWithKnownHosts(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	server := newSftpServer(t)
+	defer server.Close()
+	path := filepath.Join(fixtureDir, "oneline.txt")
+
+	home, err := ioutil.TempDir("", "home")
+	require.Nil(err)
+	defer os.RemoveAll(home)
+	setEnv(t, "HOME", home)
+
+	// without a way to verify the key of the host, the connection is refused
+	uri, err := url.Parse(server.URI("forseti:pass", path, ""))
+	require.Nil(err)
+	_, err = GetFile(*uri, defaultTimeout)
+	assert.EqualError(err, "the key of the host can not be verified: add it to ~/.ssh/known_hosts (ssh-keyscan), "+
+		"set the parameter fingerprint or known_hosts of the uri, or insecure=true to disable the verification")
+
+	line := knownhosts.Line([]string{knownhosts.Normalize(server.listener.Addr().String())},
+		server.hostKey.PublicKey())
+	knownHosts := filepath.Join(home, "known_hosts")
+	require.Nil(ioutil.WriteFile(knownHosts, []byte(line+"\n"), 0600))
+	readOneline(t, server.URI("forseti:pass", path, "known_hosts="+knownHosts))
+
+	// ~/.ssh/known_hosts is read by default
+	require.Nil(os.Mkdir(filepath.Join(home, ".ssh"), 0700))
+	require.Nil(os.Rename(knownHosts, filepath.Join(home, ".ssh", "known_hosts")))
+	readOneline(t, server.URI("forseti:pass", path, ""))
+
+	// the key of another host is refused
+	other := newSftpServer(t)
+	defer other.Close()
+	uri, err = url.Parse(other.URI("forseti:pass", path, ""))
+	require.Nil(err)
+	_, err = GetFile(*uri, defaultTimeout)
+	assert.Error(err)
+}
+
+func TestGetSFTPFileWithPrivateKey(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	_, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	require.Nil(err)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.Nil(err)
+	ed25519Signer, err := ssh.NewSignerFromKey(ed25519Key)
+	require.Nil(err)
+	rsaSigner, err := ssh.NewSignerFromKey(rsaKey)
+	require.Nil(err)
+
+	server := newSftpServer(t, ed25519Signer.PublicKey(), rsaSigner.PublicKey())
+	defer server.Close()
+	path := filepath.Join(fixtureDir, "oneline.txt")
+
+	keyFile := writePrivateKey(t, ed25519Key, "")
+	defer os.Remove(keyFile)
+	readOneline(t, server.URI("forseti", path, "insecure=true&key="+keyFile))
+
+	encryptedKeyFile := writePrivateKey(t, rsaKey, "secret")
+	defer os.Remove(encryptedKeyFile)
+	readOneline(t, server.URI("forseti", path, "insecure=true&passphrase=secret&key="+encryptedKeyFile))
+
+	uri, err := url.Parse(server.URI("forseti", path, "insecure=true&passphrase=wrong&key="+encryptedKeyFile))
+	require.Nil(err)
+	_, err = GetFile(*uri, defaultTimeout)
+	assert.Error(err)
+
+	uri, err = url.Parse(server.URI("forseti", path, "insecure=true&key="+encryptedKeyFile))
+	require.Nil(err)
+	_, err = GetFile(*uri, defaultTimeout)
+	assert.Error(err)
+
+	// a key not authorized by the server is refused
+	unknownKeyFile := writePrivateKey(t, rsaKey, "")
+	defer os.Remove(unknownKeyFile)
+	other := newSftpServer(t, ed25519Signer.PublicKey())
+	defer other.Close()
+	uri, err = url.Parse(other.URI("forseti", path, "insecure=true&key="+unknownKeyFile))
+	require.Nil(err)
+	_, err = GetFile(*uri, defaultTimeout)
+	assert.Error(err)
+}
+
+func TestGetSFTPFileWithAgent(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.Nil(err)
+	signer, err := ssh.NewSignerFromKey(key)
+	require.Nil(err)
+	server := newSftpServer(t, signer.PublicKey())
+	defer server.Close()
+	path := filepath.Join(fixtureDir, "oneline.txt")
+
+	dir, err := ioutil.TempDir("", "agent")
+	require.Nil(err)
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "agent.sock")
+	listener, err := net.Listen("unix", socket)
+	require.Nil(err)
+	defer listener.Close()
+	keyring := agent.NewKeyring()
+	require.Nil(keyring.Add(agent.AddedKey{PrivateKey: key}))
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_ = agent.ServeAgent(keyring, conn)
+			}()
+		}
+	}()
+
+	setEnv(t, "SSH_AUTH_SOCK", socket)
+	readOneline(t, server.URI("forseti", path, "insecure=true&agent=true"))
+
+	// without the agent, the user is not authenticated
+	uri, err := url.Parse(server.URI("forseti", path, "insecure=true"))
+	require.Nil(err)
+	_, err = GetFile(*uri, defaultTimeout)
+	assert.Error(err)
+
+	// the agent is only used to open the connection
+	CloseSftpConnections()
+	setEnv(t, "SSH_AUTH_SOCK", filepath.Join(dir, "none.sock"))
+	uri, err = url.Parse(server.URI("forseti", path, "insecure=true&agent=true"))
+	require.Nil(err)
+	_, err = GetFile(*uri, defaultTimeout)
+	require.Error(err)
+	assert.Contains(err.Error(), "impossible to connect to the ssh-agent")
+}
+
+func TestGetSFTPFileReusesConnection(t *testing.T) {
+	assert := assert.New(t)
+
+	server := newSftpServer(t)
+	defer server.Close()
+	defer CloseSftpConnections()
+	path := filepath.Join(fixtureDir, "oneline.txt")
+
+	readOneline(t, server.URI("forseti:pass", path, "insecure=true"))
+	readOneline(t, server.URI("forseti:pass", path, "insecure=true"))
+	assert.Equal(1, server.Connections())
+
+	// the connection lost is opened again
+	server.Disconnect()
+	readOneline(t, server.URI("forseti:pass", path, "insecure=true"))
+	assert.Equal(1, server.Connections())
+
+	// a missing file does not close the connection
+	uri, err := url.Parse(server.URI("forseti:pass", filepath.Join(fixtureDir, "not.txt"), "insecure=true"))
+	require.Nil(t, err)
+	_, err = GetFile(*uri, defaultTimeout)
+	assert.Error(err)
+	readOneline(t, server.URI("forseti:pass", path, "insecure=true"))
+	assert.Equal(1, server.Connections())
+
+	// another user has its own connection
+	readOneline(t, server.URI("forseti:pass", path, "fingerprint="+url.QueryEscape(
+		ssh.FingerprintSHA256(server.hostKey.PublicKey()))))
+	assert.Equal(2, server.Connections())
+}
+
+func TestSftpIdleConnectionClosed(t *testing.T) {
+	assert := assert.New(t)
+
+	defer func(keepAlive, idleTimeout time.Duration) {
+		sftpKeepAlive, sftpIdleTimeout = keepAlive, idleTimeout
+	}(sftpKeepAlive, sftpIdleTimeout)
+	sftpKeepAlive, sftpIdleTimeout = 10*time.Millisecond, 50*time.Millisecond
+
+	server := newSftpServer(t)
+	defer server.Close()
+	defer CloseSftpConnections()
+	uri := server.URI("forseti:pass", filepath.Join(fixtureDir, "oneline.txt"), "insecure=true")
+	readOneline(t, uri)
+
+	parsed, err := url.Parse(uri)
+	require.Nil(t, err)
+	key := sftpKey(*parsed)
+	assert.Eventually(func() bool {
+		sftpClients.mutex.Lock()
+		defer sftpClients.mutex.Unlock()
+		_, ok := sftpClients.clients[key]
+		return !ok
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestSftpConnectionInUseNotClosed(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	defer func(keepAlive, idleTimeout time.Duration) {
+		sftpKeepAlive, sftpIdleTimeout = keepAlive, idleTimeout
+	}(sftpKeepAlive, sftpIdleTimeout)
+	sftpKeepAlive, sftpIdleTimeout = 10*time.Millisecond, 50*time.Millisecond
+
+	server := newSftpServer(t)
+	defer server.Close()
+	defer CloseSftpConnections()
+	uri, err := url.Parse(server.URI("forseti:pass", filepath.Join(fixtureDir, "oneline.txt"), "insecure=true"))
+	require.Nil(err)
+
+	// a slow reader keeps the connection open longer than the idle timeout
+	reader, err := GetFile(*uri, defaultTimeout)
+	require.Nil(err)
+	time.Sleep(4 * sftpIdleTimeout)
+	b, err := ioutil.ReadAll(reader)
+	require.Nil(err)
+	assert.Equal(oneline, string(b))
+	require.Nil(reader.Close())
+
+	// the connection is idle once the file is closed
+	key := sftpKey(*uri)
+	assert.Eventually(func() bool {
+		sftpClients.mutex.Lock()
+		defer sftpClients.mutex.Unlock()
+		_, ok := sftpClients.clients[key]
+		return !ok
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestGetSFTPFileIfModified(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	server := newSftpServer(t)
+	defer server.Close()
+	defer CloseSftpConnections()
+
+	uri, err := url.Parse(server.URI("forseti:pass", filepath.Join(fixtureDir, "oneline.txt"), "insecure=true"))
+	require.Nil(err)
+	file, err := GetFileIfModified(*uri, defaultTimeout, FileVersion{})
+	require.Nil(err)
+	require.Nil(file.Close())
+	version := file.Version()
+	assert.Equal(int64(len(oneline)), version.Size)
+	assert.False(version.ModTime.IsZero())
+
+	// the file is not downloaded if its modification time and its size have not changed
+	_, err = GetFileIfModified(*uri, defaultTimeout, version)
+	assert.Equal(ErrNotModified, err)
+	version.Size++
+	file, err = GetFileIfModified(*uri, defaultTimeout, version)
+	require.Nil(err)
+	require.Nil(file.Close())
+}
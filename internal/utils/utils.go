@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"math"
@@ -54,6 +55,18 @@ func CalculateOccupancy(charge int) int {
 	return occupancy
 }
 
+// Sleep pauses the current goroutine for the duration, it returns false if the context is cancelled before
+func Sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
 func GetHttpClient(url, token, header string, connectionTimeout time.Duration) (*http.Response, error) {
 	client := &http.Client{Timeout: 10 * connectionTimeout}
 	req, err := http.NewRequest("GET", url, nil)
@@ -1,6 +1,7 @@
 package vehiclelocations
 
 import (
+	"context"
 	"fmt"
 	"net/url"
 	"strconv"
@@ -8,6 +9,7 @@ import (
 	"time"
 
 	"github.com/CanalTP/forseti/internal/connectors"
+	"github.com/CanalTP/forseti/internal/utils"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 )
@@ -94,9 +96,11 @@ func (d *GtfsRtContext) InitContext(filesURI, externalURI url.URL, externalToken
 }
 
 // main loop to refresh vehicle_locations
-func (d *GtfsRtContext) RefreshVehicleLocationsLoop() {
+func (d *GtfsRtContext) RefreshVehicleLocationsLoop(ctx context.Context) {
 	// Wait 10 seconds before reloading vehiclelocation informations
-	time.Sleep(10 * time.Second)
+	if !utils.Sleep(ctx, 10*time.Second) {
+		return
+	}
 	for {
 		err := refreshVehicleLocations(d, d.connector, d.navitia)
 		if err != nil {
@@ -104,7 +108,9 @@ func (d *GtfsRtContext) RefreshVehicleLocationsLoop() {
 		} else {
 			logrus.Debug("vehicle_locations GTFS-RT data updated")
 		}
-		time.Sleep(d.connector.GetRefreshTime())
+		if !utils.Sleep(ctx, d.connector.GetRefreshTime()) {
+			return
+		}
 	}
 }
 
@@ -1,6 +1,7 @@
 package vehiclelocations
 
 import (
+	"context"
 	"fmt"
 	"net/url"
 	"time"
@@ -17,7 +18,7 @@ type IConnectors interface {
 		loadExternalRefresh, occupancyCleanVJ, occupancyCleanVO, connectionTimeout time.Duration,
 		location *time.Location, reloadActive bool)
 
-	RefreshVehicleLocationsLoop()
+	RefreshVehicleLocationsLoop(ctx context.Context)
 
 	GetVehicleLocations(param *VehicleLocationRequestParameter) (
 		vehicleLocations []VehicleLocation, e error)
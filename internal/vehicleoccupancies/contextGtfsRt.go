@@ -1,6 +1,7 @@
 package vehicleoccupancies
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -118,12 +119,14 @@ func (d *VehicleOccupanciesGtfsRtContext) InitContext(filesURI, externalURI url.
 }
 
 // main loop to refresh vehicle_occupancies from Gtfs-rt flux
-func (d *VehicleOccupanciesGtfsRtContext) RefreshVehicleOccupanciesLoop(externalURI url.URL,
+func (d *VehicleOccupanciesGtfsRtContext) RefreshVehicleOccupanciesLoop(ctx context.Context, externalURI url.URL,
 	externalToken string, navitiaURI url.URL, navitiaToken string, loadExternalRefresh, occupancyCleanVJ,
 	occupancyCleanVO, connectionTimeout time.Duration, location *time.Location) {
 
 	// Wait 10 seconds before reloading vehicleoccupacy informations
-	time.Sleep(10 * time.Second)
+	if !utils.Sleep(ctx, 10*time.Second) {
+		return
+	}
 	for {
 		err := refreshVehicleOccupancies(d, externalURI, externalToken, navitiaURI, navitiaToken, occupancyCleanVJ,
 			occupancyCleanVO, connectionTimeout, location)
@@ -132,7 +135,9 @@ func (d *VehicleOccupanciesGtfsRtContext) RefreshVehicleOccupanciesLoop(external
 		} else {
 			logrus.Debug("vehicle_occupancies GTFS-RT data updated")
 		}
-		time.Sleep(loadExternalRefresh)
+		if !utils.Sleep(ctx, loadExternalRefresh) {
+			return
+		}
 	}
 }
 
@@ -1,6 +1,7 @@
 package vehicleoccupancies
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"math"
@@ -152,7 +153,7 @@ func (d *VehicleOccupanciesOditiContext) InitContext(filesURI, externalURI url.U
 }
 
 // main loop to refresh vehicle_occupancies from ODITI
-func (d *VehicleOccupanciesOditiContext) RefreshVehicleOccupanciesLoop(externalURI url.URL,
+func (d *VehicleOccupanciesOditiContext) RefreshVehicleOccupanciesLoop(ctx context.Context, externalURI url.URL,
 	externalToken string, navitiaURI url.URL, navitiaToken string, loadExternalRefresh, occupancyCleanVJ,
 	occupancyCleanVO, connectionTimeout time.Duration, location *time.Location) {
 	if len(externalURI.String()) == 0 || loadExternalRefresh.Seconds() <= 0 {
@@ -165,7 +166,9 @@ func (d *VehicleOccupanciesOditiContext) RefreshVehicleOccupanciesLoop(externalU
 		logrus.Error("VEHICLE_OCCUPANCIES: routine Vehicle_occupancies stopped, no stopPoints or courses loaded at start")
 	} else {
 		// Wait 10 seconds before reloading vehicleoccupacy informations
-		time.Sleep(10 * time.Second)
+		if !utils.Sleep(ctx, 10*time.Second) {
+			return
+		}
 		for {
 			err := RefreshVehicleOccupancies(d, externalURI, externalToken, connectionTimeout, location)
 			if err != nil {
@@ -173,13 +176,15 @@ func (d *VehicleOccupanciesOditiContext) RefreshVehicleOccupanciesLoop(externalU
 			} else {
 				logrus.Debug("vehicle_occupancies data updated")
 			}
-			time.Sleep(loadExternalRefresh)
+			if !utils.Sleep(ctx, loadExternalRefresh) {
+				return
+			}
 		}
 	}
 }
 
-func (d *VehicleOccupanciesOditiContext) RefreshDataFromNavitia(navitiaURI url.URL, navitiaToken string,
-	routeScheduleRefresh, connectionTimeout time.Duration, location *time.Location) {
+func (d *VehicleOccupanciesOditiContext) RefreshDataFromNavitia(ctx context.Context, navitiaURI url.URL,
+	navitiaToken string, routeScheduleRefresh, connectionTimeout time.Duration, location *time.Location) {
 
 	if len(navitiaURI.String()) == 0 || routeScheduleRefresh.Seconds() <= 0 {
 		logrus.Debug("RouteSchedule data refreshing is disabled")
@@ -197,7 +202,9 @@ func (d *VehicleOccupanciesOditiContext) RefreshDataFromNavitia(navitiaURI url.U
 			} else {
 				logrus.Debug("RouteSchedule data updated")
 			}
-			time.Sleep(routeScheduleRefresh)
+			if !utils.Sleep(ctx, routeScheduleRefresh) {
+				return
+			}
 		}
 	}
 }
@@ -254,6 +261,7 @@ func LoadStopPoints(uri url.URL, connectionTimeout time.Duration) (map[string]St
 		VehicleOccupanciesLoadingErrors.Inc()
 		return nil, err
 	}
+	defer file.Close()
 
 	stopPointsConsumer := makeStopPointLineConsumer()
 	loadDataOptions := utils.LoadDataOptions{
@@ -265,6 +273,9 @@ func LoadStopPoints(uri url.URL, connectionTimeout time.Duration) (map[string]St
 	if err != nil {
 		return nil, err
 	}
+	if err = file.Done(); err != nil {
+		logrus.Errorf("Impossible to archive or delete %s: %s", uri.Path, err)
+	}
 	return stopPointsConsumer.stopPoints, nil
 }
 
@@ -276,6 +287,7 @@ func LoadCourses(uri url.URL, connectionTimeout time.Duration) (map[string][]Cou
 		VehicleOccupanciesLoadingErrors.Inc()
 		return nil, err
 	}
+	defer file.Close()
 
 	courseLineConsumer := makeCourseLineConsumer()
 	loadDataOptions := utils.LoadDataOptions{
@@ -288,6 +300,9 @@ func LoadCourses(uri url.URL, connectionTimeout time.Duration) (map[string][]Cou
 		fmt.Println("LoadCourses Error: ", err.Error())
 		return nil, err
 	}
+	if err = file.Done(); err != nil {
+		logrus.Errorf("Impossible to archive or delete %s: %s", uri.Path, err)
+	}
 	return courseLineConsumer.courses, nil
 }
 
@@ -5,6 +5,7 @@ package vehicleoccupancies
 // To implement an interface, the contexts must imperatively implement all the methods declared in it.
 
 import (
+	"context"
 	"fmt"
 	"net/url"
 	"time"
@@ -17,7 +18,7 @@ type IVehicleOccupancy interface {
 		externalToken string, navitiaURI url.URL, navitiaToken string, loadExternalRefresh, occupancyCleanVJ,
 		occupancyCleanVO, connectionTimeout time.Duration, location *time.Location, occupancyActive bool)
 
-	RefreshVehicleOccupanciesLoop(predictionURI url.URL, externalToken string,
+	RefreshVehicleOccupanciesLoop(ctx context.Context, predictionURI url.URL, externalToken string,
 		navitiaURI url.URL, navitiaToken string, loadExternalRefresh, occupancyCleanVJ, occupancyCleanVO,
 		connectionTimeout time.Duration, location *time.Location)
 
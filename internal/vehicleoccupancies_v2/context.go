@@ -1,7 +1,9 @@
 package vehicleoccupanciesv2
 
 import (
+	"encoding/gob"
 	"fmt"
+	"io"
 	"sync"
 	"time"
 
@@ -15,21 +17,6 @@ type VehicleOccupanciesContext struct {
 	VehicleOccupancies           map[int]*VehicleOccupancy
 	lastVehicleOccupanciesUpdate time.Time
 	vehicleOccupanciesMutex      sync.RWMutex
-	loadOccupancyData            bool
-	refreshTime                  time.Duration
-}
-
-func (d *VehicleOccupanciesContext) ManageVehicleOccupancyStatus(activate bool) {
-	d.vehicleOccupanciesMutex.Lock()
-	defer d.vehicleOccupanciesMutex.Unlock()
-
-	d.loadOccupancyData = activate
-}
-
-func (d *VehicleOccupanciesContext) LoadOccupancyData() bool {
-	d.vehicleOccupanciesMutex.RLock()
-	defer d.vehicleOccupanciesMutex.RUnlock()
-	return d.loadOccupancyData
 }
 
 func (d *VehicleOccupanciesContext) UpdateVehicleOccupancies(vehicleOccupancies map[int]*VehicleOccupancy) {
@@ -74,6 +61,13 @@ func (d *VehicleOccupanciesContext) GetLastVehicleOccupanciesDataUpdate() time.T
 	return d.lastVehicleOccupanciesUpdate
 }
 
+func (d *VehicleOccupanciesContext) GetVehicleOccupanciesCount() int {
+	d.vehicleOccupanciesMutex.RLock()
+	defer d.vehicleOccupanciesMutex.RUnlock()
+
+	return len(d.VehicleOccupancies)
+}
+
 func (d *VehicleOccupanciesContext) GetVehiclesOccupancies() (vehicleOccupancies map[int]*VehicleOccupancy) {
 	d.vehicleOccupanciesMutex.RLock()
 	defer d.vehicleOccupanciesMutex.RUnlock()
@@ -116,18 +110,6 @@ func (d *VehicleOccupanciesContext) GetVehicleOccupancies(param *VehicleOccupanc
 	}
 }
 
-func (d *VehicleOccupanciesContext) GetRereshTime() string {
-	d.vehicleOccupanciesMutex.Lock()
-	defer d.vehicleOccupanciesMutex.Unlock()
-	return d.refreshTime.String()
-}
-
-func (d *VehicleOccupanciesContext) SetRereshTime(newRefreshTime time.Duration) {
-	d.vehicleOccupanciesMutex.Lock()
-	defer d.vehicleOccupanciesMutex.Unlock()
-	d.refreshTime = newRefreshTime
-}
-
 func NewVehicleOccupancy(id int, sourceCode, stopPointCode string, direction int, date time.Time,
 	occupancy string) (*VehicleOccupancy, error) {
 	return &VehicleOccupancy{
@@ -163,3 +145,37 @@ func FoundStopCode(vo VehicleOccupancy, stopPointCode []string) bool {
 	}
 	return false
 }
+
+// vehicleOccupanciesSnapshot is the content of the snapshot of the vehicle occupancies
+type vehicleOccupanciesSnapshot struct {
+	VehicleOccupancies map[int]*VehicleOccupancy
+	LastUpdate         time.Time
+}
+
+// WriteSnapshot writes the vehicle occupancies with the time of their last update
+func (d *VehicleOccupanciesContext) WriteSnapshot(w io.Writer) error {
+	d.vehicleOccupanciesMutex.RLock()
+	defer d.vehicleOccupanciesMutex.RUnlock()
+
+	if d.VehicleOccupancies == nil {
+		return fmt.Errorf("no vehicle occupancies to save")
+	}
+	return gob.NewEncoder(w).Encode(vehicleOccupanciesSnapshot{
+		VehicleOccupancies: d.VehicleOccupancies,
+		LastUpdate:         d.lastVehicleOccupanciesUpdate,
+	})
+}
+
+// ReadSnapshot replaces the vehicle occupancies by the ones of a snapshot, with their time of update
+func (d *VehicleOccupanciesContext) ReadSnapshot(r io.Reader) error {
+	var snapshot vehicleOccupanciesSnapshot
+	if err := gob.NewDecoder(r).Decode(&snapshot); err != nil {
+		return err
+	}
+
+	d.vehicleOccupanciesMutex.Lock()
+	defer d.vehicleOccupanciesMutex.Unlock()
+	d.VehicleOccupancies = snapshot.VehicleOccupancies
+	d.lastVehicleOccupanciesUpdate = snapshot.LastUpdate
+	return nil
+}
@@ -65,34 +65,30 @@ func (d *VehicleOccupanciesGtfsRtContext) UpdateOccupancy(vehicleoccupancy *Vehi
 
 func (d *VehicleOccupanciesGtfsRtContext) InitContext(filesURI, externalURI url.URL,
 	externalToken string, navitiaURI url.URL, navitiaToken string, loadExternalRefresh, occupancyCleanVJ,
-	occupancyCleanVO, connectionTimeout time.Duration, location *time.Location, occupancyActive bool) {
+	occupancyCleanVO, connectionTimeout time.Duration, location *time.Location) {
 
 	d.connector = connectors.NewConnector(filesURI, externalURI, externalToken, loadExternalRefresh, connectionTimeout)
 	d.voContext = &VehicleOccupanciesContext{}
-	d.voContext.ManageVehicleOccupancyStatus(occupancyActive)
-	d.voContext.SetRereshTime(loadExternalRefresh)
 }
 
-// main loop to refresh vehicle_occupancies from Gtfs-rt flux
-func (d *VehicleOccupanciesGtfsRtContext) RefreshVehicleOccupanciesLoop(externalURI url.URL,
-	externalToken string, navitiaURI url.URL, navitiaToken string, loadExternalRefresh, occupancyCleanVJ,
-	occupancyCleanVO, connectionTimeout time.Duration, location *time.Location) {
+// Load refreshes the vehicle occupancies from the Gtfs-rt flux
+func (d *VehicleOccupanciesGtfsRtContext) Load(navitiaURI url.URL, navitiaToken string,
+	occupancyCleanVO time.Duration, location *time.Location) error {
+	return refreshVehicleOccupancies(d, occupancyCleanVO, location)
+}
 
-	// Wait 10 seconds before reloading vehicleoccupacy informations
-	time.Sleep(10 * time.Second)
-	for {
-		err := refreshVehicleOccupancies(d, occupancyCleanVO, location)
-		if err != nil {
-			logrus.Error("Error while loading VehicleOccupancy GTFS-RT data: ", err)
-		} else {
-			logrus.Debug("vehicle_occupancies GTFS-RT data updated")
-		}
-		time.Sleep(loadExternalRefresh)
-	}
+// LoadReferences does nothing, the Gtfs-rt flux needs no reference data
+func (d *VehicleOccupanciesGtfsRtContext) LoadReferences(navitiaURI url.URL, navitiaToken string,
+	location *time.Location) error {
+	return nil
 }
 
-func (d *VehicleOccupanciesGtfsRtContext) ManageVehicleOccupancyStatus(vehicleOccupanciesActive bool) {
-	d.voContext.ManageVehicleOccupancyStatus(vehicleOccupanciesActive)
+func (d *VehicleOccupanciesGtfsRtContext) UpdateConnector(externalURI url.URL, externalToken string,
+	loadExternalRefresh, connectionTimeout time.Duration) {
+	d.connector.SetUrl(externalURI)
+	d.connector.SetToken(externalToken)
+	d.connector.SetRefreshTime(loadExternalRefresh)
+	d.connector.SetConnectionTimeout(connectionTimeout)
 }
 
 func (d *VehicleOccupanciesGtfsRtContext) GetVehicleOccupancies(param *VehicleOccupancyRequestParameter) (
@@ -104,12 +100,8 @@ func (d *VehicleOccupanciesGtfsRtContext) GetLastVehicleOccupanciesDataUpdate()
 	return d.voContext.GetLastVehicleOccupanciesDataUpdate()
 }
 
-func (d *VehicleOccupanciesGtfsRtContext) LoadOccupancyData() bool {
-	return d.voContext.LoadOccupancyData()
-}
-
-func (d *VehicleOccupanciesGtfsRtContext) GetRereshTime() string {
-	return d.voContext.GetRereshTime()
+func (d *VehicleOccupanciesGtfsRtContext) GetVehicleOccupanciesCount() int {
+	return d.voContext.GetVehicleOccupanciesCount()
 }
 
 /********* PRIVATE FUNCTIONS *********/
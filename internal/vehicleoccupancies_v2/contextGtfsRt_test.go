@@ -43,14 +43,13 @@ func Test_InitContext(t *testing.T) {
 	urlNavitia, err := url.Parse("")
 	require.Nil(err)
 	gtfsRtContext.InitContext(*uriFile, *urlExternal, "tokenExternal_123456789", *urlNavitia, "", 300, 5000,
-		5000, 200, location, false)
+		5000, 200, location)
 	assert.Equal(gtfsRtContext.connector.GetFilesUri(), *uriFile)
 	assert.Equal(gtfsRtContext.connector.GetUrl(), *urlExternal)
 	assert.Equal(gtfsRtContext.connector.GetToken(), "tokenExternal_123456789")
 	assert.Equal(gtfsRtContext.connector.GetRefreshTime(), time.Duration(300))
 	assert.Equal(gtfsRtContext.connector.GetConnectionTimeout(), time.Duration(200))
 	assert.NotNil(gtfsRtContext.voContext)
-	assert.NotNil(gtfsRtContext.voContext.loadOccupancyData, false)
 }
 
 func Test_CleanListVehicleOccupancies(t *testing.T) {
@@ -137,12 +137,10 @@ func (d *VehicleOccupanciesOditiContext) GetDepartureDatetime(vehicleJourneyCode
 
 func (d *VehicleOccupanciesOditiContext) InitContext(filesURI, externalURI url.URL,
 	externalToken string, navitiaURI url.URL, navitiaToken string, loadExternalRefresh, occupancyCleanVJ,
-	occupancyCleanVO, connectionTimeout time.Duration, location *time.Location, occupancyActive bool) {
+	occupancyCleanVO, connectionTimeout time.Duration, location *time.Location) {
 
 	d.connector = connectors.NewConnector(filesURI, externalURI, externalToken, loadExternalRefresh, connectionTimeout)
 	d.voContext = &VehicleOccupanciesContext{}
-	d.voContext.ManageVehicleOccupancyStatus(occupancyActive)
-	d.voContext.SetRereshTime(loadExternalRefresh)
 
 	err := LoadAllForVehicleOccupancies(d, navitiaURI, navitiaToken, location)
 	if err != nil {
@@ -150,36 +148,29 @@ func (d *VehicleOccupanciesOditiContext) InitContext(filesURI, externalURI url.U
 	}
 }
 
-// main loop to refresh vehicle_occupancies from ODITI
-func (d *VehicleOccupanciesOditiContext) RefreshVehicleOccupanciesLoop(externalURI url.URL,
-	externalToken string, navitiaURI url.URL, navitiaToken string, loadExternalRefresh, occupancyCleanVJ,
-	occupancyCleanVO, connectionTimeout time.Duration, location *time.Location) {
-
-	if len(externalURI.String()) == 0 || loadExternalRefresh.Seconds() <= 0 {
-		logrus.Debug("VehicleOccupancy data refreshing is disabled")
-		return
-	}
-
+// Load refreshes the vehicle occupancies from the predictions of ODITI, the stop points and the courses
+// must have been loaded at start
+func (d *VehicleOccupanciesOditiContext) Load(navitiaURI url.URL, navitiaToken string,
+	occupancyCleanVO time.Duration, location *time.Location) error {
 	if (d.courses == nil || d.stopPoints == nil) ||
 		(len(*d.courses) == 0 || len(*d.stopPoints) == 0) {
-		logrus.Error("Vehicle occupancy ODITI: routine Vehicle_occupancies stopped, no stopPoints or courses loaded at start")
-	} else {
-		// Wait 10 seconds before reloading vehicleoccupacy informations
-		time.Sleep(10 * time.Second)
-		for {
-			err := RefreshVehicleOccupancies(d, occupancyCleanVO, navitiaURI, navitiaToken, location)
-			if err != nil {
-				logrus.Error("Error while reloading VehicleOccupancy data: ", err)
-			} else {
-				logrus.Debug("vehicle_occupancies data updated")
-			}
-			time.Sleep(loadExternalRefresh)
-		}
+		return fmt.Errorf("no stopPoints or courses loaded at start")
 	}
+	return RefreshVehicleOccupancies(d, occupancyCleanVO, navitiaURI, navitiaToken, location)
 }
 
-func (d *VehicleOccupanciesOditiContext) ManageVehicleOccupancyStatus(vehicleOccupanciesActive bool) {
-	d.voContext.ManageVehicleOccupancyStatus(vehicleOccupanciesActive)
+// LoadReferences reloads the stop points, the courses and the vehicle journeys of navitia
+func (d *VehicleOccupanciesOditiContext) LoadReferences(navitiaURI url.URL, navitiaToken string,
+	location *time.Location) error {
+	return LoadAllForVehicleOccupancies(d, navitiaURI, navitiaToken, location)
+}
+
+func (d *VehicleOccupanciesOditiContext) UpdateConnector(externalURI url.URL, externalToken string,
+	loadExternalRefresh, connectionTimeout time.Duration) {
+	d.connector.SetUrl(externalURI)
+	d.connector.SetToken(externalToken)
+	d.connector.SetRefreshTime(loadExternalRefresh)
+	d.connector.SetConnectionTimeout(connectionTimeout)
 }
 
 func (d *VehicleOccupanciesOditiContext) GetVehicleOccupancies(param *VehicleOccupancyRequestParameter) (
@@ -191,23 +182,14 @@ func (d *VehicleOccupanciesOditiContext) GetLastVehicleOccupanciesDataUpdate() t
 	return d.voContext.GetLastVehicleOccupanciesDataUpdate()
 }
 
-func (d *VehicleOccupanciesOditiContext) LoadOccupancyData() bool {
-	return d.voContext.LoadOccupancyData()
-}
-
-func (d *VehicleOccupanciesOditiContext) GetRereshTime() string {
-	return d.voContext.GetRereshTime()
+func (d *VehicleOccupanciesOditiContext) GetVehicleOccupanciesCount() int {
+	return d.voContext.GetVehicleOccupanciesCount()
 }
 
 /********* PRIVATE FUNCTIONS *********/
 
 func RefreshVehicleOccupancies(context *VehicleOccupanciesOditiContext, occupancyCleanVO time.Duration,
 	navitiaURI url.URL, navitiaToken string, location *time.Location) error {
-	// Continue using last loaded data if loading is deactivated
-	if !context.voContext.LoadOccupancyData() {
-		return nil
-	}
-
 	begin := time.Now()
 	predictions, err := LoadPredictions(context.connector, location)
 	if len(predictions) == 0 {
@@ -278,6 +260,7 @@ func LoadStopPoints(uri url.URL, connectionTimeout time.Duration) (map[string]St
 		VehicleOccupanciesLoadingErrors.Inc()
 		return nil, err
 	}
+	defer file.Close()
 
 	stopPointsConsumer := makeStopPointLineConsumer()
 	loadDataOptions := utils.LoadDataOptions{
@@ -289,6 +272,9 @@ func LoadStopPoints(uri url.URL, connectionTimeout time.Duration) (map[string]St
 	if err != nil {
 		return nil, err
 	}
+	if err = file.Done(); err != nil {
+		logrus.Errorf("Impossible to archive or delete %s: %s", uri.Path, err)
+	}
 	return stopPointsConsumer.stopPoints, nil
 }
 
@@ -300,6 +286,7 @@ func LoadCourses(uri url.URL, connectionTimeout time.Duration) (map[string][]Cou
 		VehicleOccupanciesLoadingErrors.Inc()
 		return nil, err
 	}
+	defer file.Close()
 
 	courseLineConsumer := makeCourseLineConsumer()
 	loadDataOptions := utils.LoadDataOptions{
@@ -312,6 +299,9 @@ func LoadCourses(uri url.URL, connectionTimeout time.Duration) (map[string][]Cou
 		fmt.Println("LoadCourses Error: ", err.Error())
 		return nil, err
 	}
+	if err = file.Done(); err != nil {
+		logrus.Errorf("Impossible to archive or delete %s: %s", uri.Path, err)
+	}
 	return courseLineConsumer.courses, nil
 }
 
@@ -310,3 +310,31 @@ func TestLoadPredictionsFromFile(t *testing.T) {
 	assert.Equal(predictions[0].StopName, "Residence Europe")
 	assert.Equal(predictions[0].Occupancy, 5)
 }
+
+func TestLoadReferentialFromZipArchive(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	SpFileName = "mapping_stops.csv"
+	CourseFileName = "extraction_courses.csv"
+
+	// oditi.zip contains mapping_stops.csv and extraction_courses.csv
+	uri, err := url.Parse(fmt.Sprintf("file://%s/", fixtureDir))
+	require.Nil(err)
+	zipURI, err := url.Parse(fmt.Sprintf("file://%s/oditi.zip", fixtureDir))
+	require.Nil(err)
+
+	stopPoints, err := LoadStopPoints(*uri, defaultTimeout)
+	require.Nil(err)
+	zipStopPoints, err := LoadStopPoints(*zipURI, defaultTimeout)
+	require.Nil(err)
+	assert.NotEmpty(zipStopPoints)
+	assert.Equal(stopPoints, zipStopPoints)
+
+	courses, err := LoadCourses(*uri, defaultTimeout)
+	require.Nil(err)
+	zipCourses, err := LoadCourses(*zipURI, defaultTimeout)
+	require.Nil(err)
+	assert.NotEmpty(zipCourses)
+	assert.Equal(courses, zipCourses)
+}
@@ -3,8 +3,10 @@ package vehicleoccupanciesv2
 import (
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"time"
 
+	"github.com/CanalTP/forseti/internal/breaker"
 	"github.com/CanalTP/forseti/internal/connectors"
 	"github.com/CanalTP/forseti/internal/data"
 	"github.com/CanalTP/forseti/internal/utils"
@@ -23,13 +25,14 @@ func LoadPredictions(connector *connectors.Connector, location *time.Location) (
 	urlPath := connector.GetUrl()
 	callUrl := fmt.Sprintf("%s/futuredata/getfuturedata?start_time=%s&end_time=%s", urlPath.String(), start_date, end_date)
 	header := "Ocp-Apim-Subscription-Key"
-	resp, err := utils.GetHttpClient(callUrl, connector.GetToken(), header, connector.GetConnectionTimeout())
-	if err != nil {
-		VehicleOccupanciesLoadingErrors.Inc()
-		return nil, err
-	}
-
-	err = utils.CheckResponseStatus(resp)
+	var resp *http.Response
+	err := breaker.Call("oditi:"+urlPath.Host, func() (err error) {
+		resp, err = utils.GetHttpClient(callUrl, connector.GetToken(), header, connector.GetConnectionTimeout())
+		if err != nil {
+			return err
+		}
+		return utils.CheckResponseStatus(resp)
+	})
 	if err != nil {
 		VehicleOccupanciesLoadingErrors.Inc()
 		return nil, err
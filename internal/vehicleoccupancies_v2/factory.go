@@ -15,22 +15,22 @@ import (
 type IVehicleOccupancy interface {
 	InitContext(filesURI, externalURI url.URL,
 		externalToken string, navitiaURI url.URL, navitiaToken string, loadExternalRefresh, occupancyCleanVJ,
-		occupancyCleanVO, connectionTimeout time.Duration, location *time.Location, occupancyActive bool)
+		occupancyCleanVO, connectionTimeout time.Duration, location *time.Location)
 
-	RefreshVehicleOccupanciesLoop(predictionURI url.URL, externalToken string,
-		navitiaURI url.URL, navitiaToken string, loadExternalRefresh, occupancyCleanVJ, occupancyCleanVO,
-		connectionTimeout time.Duration, location *time.Location)
+	Load(navitiaURI url.URL, navitiaToken string, occupancyCleanVO time.Duration, location *time.Location) error
+
+	LoadReferences(navitiaURI url.URL, navitiaToken string, location *time.Location) error
+
+	UpdateConnector(externalURI url.URL, externalToken string, loadExternalRefresh, connectionTimeout time.Duration)
 
 	GetVehicleOccupancies(param *VehicleOccupancyRequestParameter) (
 		vehicleOccupancies []VehicleOccupancy, e error)
 
-	ManageVehicleOccupancyStatus(vehicleOccupanciesActive bool)
-
 	GetLastVehicleOccupanciesDataUpdate() time.Time
 
-	LoadOccupancyData() bool
+	GetVehicleOccupanciesCount() int
 
-	GetRereshTime() string
+	GetVehicleOccupanciesContext() *VehicleOccupanciesContext
 }
 
 // Patern factory Vehicle occupancies
@@ -10,6 +10,7 @@ import (
 	"net/url"
 	"time"
 
+	"github.com/CanalTP/forseti/internal/breaker"
 	"github.com/CanalTP/forseti/internal/utils"
 )
 
@@ -128,15 +129,21 @@ func GetVehiclesJourneysWithLine(codeLine string, uri url.URL, token string, con
 	return CreateVehicleJourney(navitiaVJ, time.Now()), nil
 }
 
-// This method call Navitia api with specific url and return a request response
+// This method call Navitia api with specific url and return a request response,
+// the calls are rejected while the circuit breaker of the host of Navitia is open
 func CallNavitia(callUrl string, token string, connectionTimeout time.Duration) (*http.Response, error) {
-	resp, err := utils.GetHttpClient(callUrl, token, "Authorization", connectionTimeout)
-	if err != nil {
-		VehicleOccupanciesLoadingErrors.Inc()
-		return nil, err
+	upstream := "navitia"
+	if uri, err := url.Parse(callUrl); err == nil {
+		upstream += ":" + uri.Host
 	}
-
-	err = utils.CheckResponseStatus(resp)
+	var resp *http.Response
+	err := breaker.Call(upstream, func() (err error) {
+		resp, err = utils.GetHttpClient(callUrl, token, "Authorization", connectionTimeout)
+		if err != nil {
+			return err
+		}
+		return utils.CheckResponseStatus(resp)
+	})
 	if err != nil {
 		VehicleOccupanciesLoadingErrors.Inc()
 		return nil, err
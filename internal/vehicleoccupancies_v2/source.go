@@ -0,0 +1,257 @@
+package vehicleoccupanciesv2
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+
+	"github.com/CanalTP/forseti/internal/sources"
+)
+
+const ModuleName = "vehicle_occupancies"
+
+func init() {
+	sources.Register(sources.Module{
+		Name: ModuleName,
+		Parameters: []sources.Parameter{
+			{Name: "files-uri", Flag: "occupancy-files-uri", Default: "",
+				Usage: sources.FileURIUsage},
+			{Name: "navitia-uri", Flag: "occupancy-navitia-uri", Default: "",
+				Usage: "format: [scheme:][//[userinfo@]host][/]path"},
+			{Name: "navitia-token", Flag: "occupancy-navitia-token", Default: "",
+				Usage: "token for navitia"},
+			{Name: "uri", Flag: "occupancy-service-uri", Default: "",
+				Usage: "format: [scheme:][//[userinfo@]host][/]path"},
+			{Name: "token", Flag: "occupancy-service-token", Default: "",
+				Usage: "token for prediction source"},
+			{Name: "refresh-active", Flag: "occupancy-service-refresh-active", Default: false,
+				Usage: "activate the periodic refresh of vehicle occupancy data"},
+			{Name: "refresh", Flag: "occupancy-refresh", Default: 5 * time.Minute,
+				Usage: "time between refresh of predictions"},
+			{Name: "routeschedule-refresh", Flag: "routeschedule-refresh", Default: 24 * time.Hour,
+				Usage: "time between refresh of RouteSchedules from navitia"},
+			{Name: "clean-vj", Flag: "occupancy-clean-vj", Default: 24 * time.Hour,
+				Usage: "time between clean list of VehicleJourneys"},
+			{Name: "clean-vo", Flag: "occupancy-clean-vo", Default: 2 * time.Hour,
+				Usage: "time between clean list of VehicleOccupancies"},
+			{Name: "connector", Flag: "connector-type", Default: "oditi",
+				Usage: "connector type to load data source"},
+			sources.TimezoneLocation,
+			sources.ConnectionTimeout,
+			sources.BackoffInitial,
+			sources.BackoffMax,
+			sources.BackoffJitter,
+			sources.SnapshotDir,
+			sources.SnapshotInterval,
+		},
+		New: NewSourceFromConfig,
+	})
+}
+
+type Config struct {
+	FilesURI             string        `mapstructure:"files-uri"`
+	NavitiaURI           string        `mapstructure:"navitia-uri"`
+	NavitiaToken         string        `mapstructure:"navitia-token"`
+	URI                  string        `mapstructure:"uri"`
+	Token                string        `mapstructure:"token"`
+	RefreshActive        bool          `mapstructure:"refresh-active"`
+	Refresh              time.Duration `mapstructure:"refresh"`
+	RouteScheduleRefresh time.Duration `mapstructure:"routeschedule-refresh"`
+	CleanVJ              time.Duration `mapstructure:"clean-vj"`
+	CleanVO              time.Duration `mapstructure:"clean-vo"`
+	Connector            string        `mapstructure:"connector"`
+	TimeZoneLocation     string        `mapstructure:"timezone-location"`
+	ConnectionTimeout    time.Duration `mapstructure:"connection-timeout"`
+	sources.Backoff      `mapstructure:",squash"`
+	sources.Snapshot     `mapstructure:",squash"`
+}
+
+// VehicleOccupanciesSource serves the vehicle occupancies loaded periodically from an external service
+type VehicleOccupanciesSource struct {
+	*sources.Refresher
+	instance     string
+	context      IVehicleOccupancy
+	uri          url.URL
+	navitiaURI   url.URL
+	navitiaToken string
+	cleanVO      time.Duration
+	location     *time.Location
+	config       Config
+	mutex        sync.RWMutex
+}
+
+// NewVehicleOccupanciesSource creates the source with a deactivated refresh, the first load is done 10 seconds
+// after the start
+func NewVehicleOccupanciesSource(context IVehicleOccupancy, uri url.URL, token string, navitiaURI url.URL,
+	navitiaToken string, refresh, cleanVJ, cleanVO, connectionTimeout time.Duration,
+	location *time.Location) *VehicleOccupanciesSource {
+	source := &VehicleOccupanciesSource{
+		context:      context,
+		uri:          uri,
+		navitiaURI:   navitiaURI,
+		navitiaToken: navitiaToken,
+		cleanVO:      cleanVO,
+		location:     location,
+	}
+	source.Refresher = sources.NewRefresher(source, refresh, 10*time.Second, false)
+	return source
+}
+
+func NewSourceFromConfig(instance string, config *viper.Viper) (sources.Source, error) {
+	var c Config
+	if err := config.Unmarshal(&c); err != nil {
+		return nil, err
+	}
+	if len(c.NavitiaURI) == 0 || len(c.URI) == 0 {
+		return nil, nil
+	}
+
+	filesURI, err := url.Parse(c.FilesURI)
+	if err != nil {
+		return nil, err
+	}
+	uri, err := url.Parse(c.URI)
+	if err != nil {
+		return nil, err
+	}
+	navitiaURI, err := url.Parse(c.NavitiaURI)
+	if err != nil {
+		return nil, err
+	}
+	location, err := time.LoadLocation(c.TimeZoneLocation)
+	if err != nil {
+		return nil, err
+	}
+
+	context, err := VehicleOccupancyFactory(c.Connector)
+	if err != nil {
+		return nil, err
+	}
+	context.InitContext(*filesURI, *uri, c.Token, *navitiaURI, c.NavitiaToken, c.Refresh, c.CleanVJ, c.CleanVO,
+		c.ConnectionTimeout, location)
+
+	source := NewVehicleOccupanciesSource(context, *uri, c.Token, *navitiaURI, c.NavitiaToken, c.Refresh,
+		c.CleanVJ, c.CleanVO, c.ConnectionTimeout, location)
+	source.ManageRefreshStatus(c.RefreshActive)
+	source.SetBackoff(c.Backoff)
+	source.SetSnapshot(c.Snapshot)
+	source.instance = instance
+	source.config = c
+	return source, nil
+}
+
+func (s *VehicleOccupanciesSource) GetContext() IVehicleOccupancy {
+	return s.context
+}
+
+/********* INTERFACE METHODS IMPLEMENTS *********/
+
+func (s *VehicleOccupanciesSource) Name() string {
+	return ModuleName
+}
+
+func (s *VehicleOccupanciesSource) Instance() string {
+	return s.instance
+}
+
+func (s *VehicleOccupanciesSource) Start() {
+	s.mutex.RLock()
+	disabled := len(s.uri.String()) == 0 || s.GetRefresh().Seconds() <= 0
+	s.mutex.RUnlock()
+	if disabled {
+		logrus.Debug("VehicleOccupancy data refreshing is disabled")
+		return
+	}
+	s.Refresher.Start()
+}
+
+func (s *VehicleOccupanciesSource) LoadReferences() error {
+	s.mutex.RLock()
+	navitiaURI, navitiaToken, location := s.navitiaURI, s.navitiaToken, s.location
+	s.mutex.RUnlock()
+	return s.context.LoadReferences(navitiaURI, navitiaToken, location)
+}
+
+func (s *VehicleOccupanciesSource) Load() error {
+	s.mutex.RLock()
+	navitiaURI, navitiaToken, cleanVO, location := s.navitiaURI, s.navitiaToken, s.cleanVO, s.location
+	s.mutex.RUnlock()
+	return s.context.Load(navitiaURI, navitiaToken, cleanVO, location)
+}
+
+// Reload applies the new uri, token, refresh and clean times to the connector and restarts the refresh,
+// the loaded occupancies are kept. The other parameters need a restart of forseti as they define the
+// data loaded at start-up. The refresh is stopped if the uri is removed from the configuration.
+func (s *VehicleOccupanciesSource) Reload(config *viper.Viper) error {
+	var c Config
+	if err := config.Unmarshal(&c); err != nil {
+		return err
+	}
+	s.SetBackoff(c.Backoff)
+	s.SetSnapshot(c.Snapshot)
+	s.mutex.RLock()
+	previous := s.config
+	s.mutex.RUnlock()
+	if c == previous && s.Running() {
+		return nil
+	}
+	if len(c.NavitiaURI) == 0 || len(c.URI) == 0 {
+		s.Stop()
+		return nil
+	}
+	if c.FilesURI != previous.FilesURI || c.NavitiaURI != previous.NavitiaURI ||
+		c.NavitiaToken != previous.NavitiaToken || c.Connector != previous.Connector ||
+		c.TimeZoneLocation != previous.TimeZoneLocation {
+		return fmt.Errorf("files-uri, navitia-uri, navitia-token, connector and timezone-location " +
+			"can not be reloaded, forseti must be restarted")
+	}
+	uri, err := url.Parse(c.URI)
+	if err != nil {
+		return err
+	}
+
+	s.Stop()
+	s.context.UpdateConnector(*uri, c.Token, c.Refresh, c.ConnectionTimeout)
+	s.mutex.Lock()
+	s.uri, s.cleanVO = *uri, c.CleanVO
+	s.config = c
+	s.mutex.Unlock()
+	s.SetRefresh(c.Refresh)
+	s.ManageRefreshStatus(c.RefreshActive)
+	s.Start()
+	return nil
+}
+
+func (s *VehicleOccupanciesSource) AddEntryPoints(r *gin.Engine) {
+	AddVehicleOccupanciesEntryPoint(r, s.context)
+}
+
+func (s *VehicleOccupanciesSource) GetStatus() sources.Status {
+	status := s.Refresher.Status()
+	status.LastUpdate = s.context.GetLastVehicleOccupanciesDataUpdate()
+	return status
+}
+
+func (s *VehicleOccupanciesSource) LoadedItems() int {
+	return s.context.GetVehicleOccupanciesCount()
+}
+
+func (s *VehicleOccupanciesSource) URI() url.URL {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.uri
+}
+
+func (s *VehicleOccupanciesSource) WriteSnapshot(w io.Writer) error {
+	return s.context.GetVehicleOccupanciesContext().WriteSnapshot(w)
+}
+
+func (s *VehicleOccupanciesSource) ReadSnapshot(r io.Reader) error {
+	return s.context.GetVehicleOccupanciesContext().ReadSnapshot(r)
+}
@@ -2,6 +2,8 @@ package vehiclepositions
 
 import (
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net/url"
 	"sync"
 	"time"
@@ -47,52 +49,65 @@ func (d *GtfsRtContext) GetVehiclePositions(param *VehiclePositionRequestParamet
 /********* INTERFACE METHODS IMPLEMENTS *********/
 
 func (d *GtfsRtContext) InitContext(filesURI, externalURI url.URL, externalToken string, loadExternalRefresh,
-	positionsCleanVP, connectionTimeout time.Duration, location *time.Location, reloadActive bool) {
+	positionsCleanVP, connectionTimeout time.Duration, location *time.Location) {
 
 	d.connector = connectors.NewConnector(filesURI, externalURI, externalToken, loadExternalRefresh, connectionTimeout)
 	d.vehiclePositions = &VehiclePositions{}
 	d.location = location
 	d.cleanVp = positionsCleanVP
-	d.vehiclePositions.ManageVehiclePositionsStatus(reloadActive)
 }
 
-// main loop to refresh vehicle_positions
-func (d *GtfsRtContext) RefreshVehiclePositionsLoop() {
-	// Wait 10 seconds before reloading vehicleposition informations
-	time.Sleep(10 * time.Second)
-	for {
-		err := refreshVehiclePositions(d, d.connector)
-		if err != nil {
-			logrus.Error("Error while loading VehiclePositions GTFS-RT data: ", err)
-		} else {
-			logrus.Info("Vehicle_positions GTFS-RT data updated")
-			logrus.Info("Vehicle_positions list size: ", len(d.vehiclePositions.vehiclePositions))
-		}
-		time.Sleep(d.connector.GetRefreshTime())
+// Load refreshes the vehicle positions from the Gtfs-rt flux
+func (d *GtfsRtContext) Load() error {
+	err := refreshVehiclePositions(d, d.connector)
+	if err != nil {
+		return err
 	}
+	logrus.Info("Vehicle_positions list size: ", len(d.vehiclePositions.vehiclePositions))
+	return nil
 }
 
-func (d *GtfsRtContext) GetLastVehiclePositionsDataUpdate() time.Time {
-	return d.vehiclePositions.GetLastVehiclePositionsDataUpdate()
+func (d *GtfsRtContext) UpdateConnector(externalURI url.URL, externalToken string,
+	loadExternalRefresh, connectionTimeout time.Duration) {
+	d.connector.SetUrl(externalURI)
+	d.connector.SetToken(externalToken)
+	d.connector.SetRefreshTime(loadExternalRefresh)
+	d.connector.SetConnectionTimeout(connectionTimeout)
 }
 
-func (d *GtfsRtContext) ManageVehiclePositionsStatus(vehiclePositionsActive bool) {
-	d.vehiclePositions.ManageVehiclePositionsStatus(vehiclePositionsActive)
+func (d *GtfsRtContext) GetLastVehiclePositionsDataUpdate() time.Time {
+	return d.vehiclePositions.GetLastVehiclePositionsDataUpdate()
 }
 
-func (d *GtfsRtContext) LoadPositionsData() bool {
-	return d.vehiclePositions.LoadPositionsData()
+func (d *GtfsRtContext) GetVehiclePositionsCount() int {
+	return d.vehiclePositions.GetVehiclePositionsCount()
 }
 
-func (d *GtfsRtContext) GetRereshTime() string {
-	return d.connector.GetRefreshTime().String()
+// LoadPayload loads the vehicle positions pushed in a GTFS-RT feed
+func (d *GtfsRtContext) LoadPayload(r io.Reader) error {
+	begin := time.Now()
+	feed, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	gtfsRt, err := gtfsrtvehiclepositions.ParseVehiclesResponse(feed)
+	if err != nil {
+		VehiclePositionsLoadingErrors.Inc()
+		return err
+	}
+	if len(gtfsRt.Vehicles) == 0 {
+		VehiclePositionsLoadingErrors.Inc()
+		return fmt.Errorf("no vehicle in the GTFS-RT feed")
+	}
+	updateVehiclePositions(d, gtfsRt)
+	VehiclePositionsLoadingDuration.Observe(time.Since(begin).Seconds())
+	return nil
 }
 
 /********* PRIVATE FUNCTIONS *********/
 
 func refreshVehiclePositions(context *GtfsRtContext, connector *connectors.Connector) error {
 	begin := time.Now()
-	timeCleanVP := start.Add(context.cleanVp)
 
 	// Get all data from Gtfs-rt flux
 	gtfsRt, err := loadDatafromConnector(connector)
@@ -103,7 +118,14 @@ func refreshVehiclePositions(context *GtfsRtContext, connector *connectors.Conne
 	if gtfsRt == nil || len(gtfsRt.Vehicles) == 0 {
 		return fmt.Errorf("no data to load from GTFS-RT")
 	}
+	updateVehiclePositions(context, gtfsRt)
+	VehiclePositionsLoadingDuration.Observe(time.Since(begin).Seconds())
+	return nil
+}
 
+// updateVehiclePositions adds or updates the vehicle positions of the GTFS-RT feed
+func updateVehiclePositions(context *GtfsRtContext, gtfsRt *gtfsrtvehiclepositions.GtfsRt) {
+	timeCleanVP := start.Add(context.cleanVp)
 	if timeCleanVP.Before(time.Now()) {
 		context.CleanListVehiclePositions(context.cleanVp)
 		start = time.Now()
@@ -142,9 +164,6 @@ func refreshVehiclePositions(context *GtfsRtContext, connector *connectors.Conne
 			}
 		}
 	}
-
-	VehiclePositionsLoadingDuration.Observe(time.Since(begin).Seconds())
-	return nil
 }
 
 func loadDatafromConnector(connector *connectors.Connector) (*gtfsrtvehiclepositions.GtfsRt, error) {
@@ -1,8 +1,10 @@
 package vehiclepositions
 
 import (
+	"fmt"
 	"net/url"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -118,7 +120,7 @@ func Test_InitContext(t *testing.T) {
 	require.Nil(err)
 	urlExternal, err := url.Parse("http://gtfs-rt/test.pb")
 	require.Nil(err)
-	gtfsRtContext.InitContext(*uriFile, *urlExternal, "tokenExternal_123456789", 300, 5000, 200, location, false)
+	gtfsRtContext.InitContext(*uriFile, *urlExternal, "tokenExternal_123456789", 300, 5000, 200, location)
 	assert.Equal(gtfsRtContext.connector.GetFilesUri(), *uriFile)
 	assert.Equal(gtfsRtContext.connector.GetUrl(), *urlExternal)
 	assert.Equal(gtfsRtContext.connector.GetToken(), "tokenExternal_123456789")
@@ -126,7 +128,6 @@ func Test_InitContext(t *testing.T) {
 	assert.Equal(gtfsRtContext.connector.GetConnectionTimeout(), time.Duration(200))
 	assert.NotNil(gtfsRtContext.vehiclePositions)
 	assert.Equal(gtfsRtContext.location, location)
-	assert.NotNil(gtfsRtContext.vehiclePositions.loadOccupancyData, false)
 }
 
 var vehiclePositionsMap = map[int]*VehiclePosition{
@@ -151,3 +152,18 @@ var vehiclePositionsMap = map[int]*VehiclePosition{
 		Occupancy:          google_transit.VehiclePosition_OccupancyStatus_name[1],
 		FeedCreatedAt:      time.Now()},
 }
+
+func TestLoadPayload(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	gtfsRtContext := &GtfsRtContext{}
+	gtfsRtContext.InitContext(url.URL{}, url.URL{}, "", time.Minute, time.Hour, time.Second, time.UTC)
+	f, err := os.Open(fmt.Sprintf("%s/vehiclePositions.pb", fixtureDir))
+	require.Nil(err)
+	defer f.Close()
+	require.Nil(gtfsRtContext.LoadPayload(f))
+	assert.NotZero(gtfsRtContext.GetVehiclePositionsCount())
+
+	assert.Error(gtfsRtContext.LoadPayload(strings.NewReader("not a feed")))
+}
@@ -14,20 +14,20 @@ import (
 
 type IConnectors interface {
 	InitContext(ilesURI, externalURI url.URL, externalToken string, loadExternalRefresh, positionCleanVO,
-		connectionTimeout time.Duration, location *time.Location, reloadActive bool)
+		connectionTimeout time.Duration, location *time.Location)
 
-	RefreshVehiclePositionsLoop()
+	Load() error
+
+	UpdateConnector(externalURI url.URL, externalToken string, loadExternalRefresh, connectionTimeout time.Duration)
 
 	GetVehiclePositions(param *VehiclePositionRequestParameter) (
 		vehiclePositions []VehiclePosition, e error)
 
 	GetLastVehiclePositionsDataUpdate() time.Time
 
-	ManageVehiclePositionsStatus(activate bool)
-
-	LoadPositionsData() bool
+	GetVehiclePositionsCount() int
 
-	GetRereshTime() string
+	GetAllVehiclePositions() *VehiclePositions
 }
 
 // Patern factory
@@ -0,0 +1,222 @@
+package vehiclepositions
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+
+	"github.com/CanalTP/forseti/internal/sources"
+)
+
+const ModuleName = "vehicle_positions"
+
+func init() {
+	sources.Register(sources.Module{
+		Name: ModuleName,
+		Parameters: []sources.Parameter{
+			{Name: "files-uri", Flag: "positions-files-uri", Default: "",
+				Usage: "format: [scheme:][//[userinfo@]host][/]path"},
+			{Name: "uri", Flag: "positions-service-uri", Default: "",
+				Usage: "format: [scheme:][//[userinfo@]host][/]path"},
+			{Name: "token", Flag: "positions-service-token", Default: "",
+				Usage: "token for positions source"},
+			{Name: "refresh-active", Flag: "positions-service-refresh-active", Default: false,
+				Usage: "activate the periodic refresh of vehicle positions data"},
+			{Name: "refresh", Flag: "positions-refresh", Default: 5 * time.Minute,
+				Usage: "time between refresh of positions"},
+			{Name: "clean-vp", Flag: "positions-clean-vp", Default: 2 * time.Hour,
+				Usage: "time between clean list of vehiclePositions"},
+			{Name: "connector", Flag: "connector-type", Default: "oditi",
+				Usage: "connector type to load data source"},
+			sources.TimezoneLocation,
+			sources.ConnectionTimeout,
+			sources.BackoffInitial,
+			sources.BackoffMax,
+			sources.BackoffJitter,
+			sources.SnapshotDir,
+			sources.SnapshotInterval,
+		},
+		New: NewSourceFromConfig,
+	})
+}
+
+type Config struct {
+	FilesURI          string        `mapstructure:"files-uri"`
+	URI               string        `mapstructure:"uri"`
+	Token             string        `mapstructure:"token"`
+	RefreshActive     bool          `mapstructure:"refresh-active"`
+	Refresh           time.Duration `mapstructure:"refresh"`
+	CleanVP           time.Duration `mapstructure:"clean-vp"`
+	Connector         string        `mapstructure:"connector"`
+	TimeZoneLocation  string        `mapstructure:"timezone-location"`
+	ConnectionTimeout time.Duration `mapstructure:"connection-timeout"`
+	sources.Backoff   `mapstructure:",squash"`
+	sources.Snapshot  `mapstructure:",squash"`
+}
+
+// VehiclePositionsSource serves the vehicle positions loaded periodically from an external service
+type VehiclePositionsSource struct {
+	*sources.Refresher
+	instance string
+	context  IConnectors
+	config   Config
+	mutex    sync.Mutex
+}
+
+// NewVehiclePositionsSource creates the source with a deactivated refresh, the first load is done 10 seconds
+// after the start
+func NewVehiclePositionsSource(context IConnectors, refresh time.Duration) *VehiclePositionsSource {
+	source := &VehiclePositionsSource{context: context}
+	source.Refresher = sources.NewRefresher(source, refresh, 10*time.Second, false)
+	return source
+}
+
+func NewSourceFromConfig(instance string, config *viper.Viper) (sources.Source, error) {
+	var c Config
+	if err := config.Unmarshal(&c); err != nil {
+		return nil, err
+	}
+	if len(c.URI) == 0 {
+		return nil, nil
+	}
+	filesURI, err := url.Parse(c.FilesURI)
+	if err != nil {
+		return nil, err
+	}
+	uri, err := url.Parse(c.URI)
+	if err != nil {
+		return nil, err
+	}
+	location, err := time.LoadLocation(c.TimeZoneLocation)
+	if err != nil {
+		return nil, err
+	}
+
+	context, err := ConnectorFactory(c.Connector)
+	if err != nil {
+		return nil, err
+	}
+	context.InitContext(*filesURI, *uri, c.Token, c.Refresh, c.CleanVP, c.ConnectionTimeout, location)
+	source := NewVehiclePositionsSource(context, c.Refresh)
+	source.ManageRefreshStatus(c.RefreshActive)
+	source.SetBackoff(c.Backoff)
+	source.SetSnapshot(c.Snapshot)
+	source.instance = instance
+	source.config = c
+	return source, nil
+}
+
+func (s *VehiclePositionsSource) GetContext() IConnectors {
+	return s.context
+}
+
+/********* INTERFACE METHODS IMPLEMENTS *********/
+
+func (s *VehiclePositionsSource) Name() string {
+	return ModuleName
+}
+
+func (s *VehiclePositionsSource) Instance() string {
+	return s.instance
+}
+
+func (s *VehiclePositionsSource) Start() {
+	s.mutex.Lock()
+	disabled := len(s.config.URI) == 0 || s.GetRefresh().Seconds() <= 0
+	s.mutex.Unlock()
+	if disabled {
+		return
+	}
+	s.Refresher.Start()
+}
+
+func (s *VehiclePositionsSource) Load() error {
+	return s.context.Load()
+}
+
+// Reload applies the new uri, token and refresh to the connector and restarts the refresh, the loaded
+// positions are kept. The other parameters need a restart of forseti. The refresh is stopped if the uri
+// is removed from the configuration.
+func (s *VehiclePositionsSource) Reload(config *viper.Viper) error {
+	var c Config
+	if err := config.Unmarshal(&c); err != nil {
+		return err
+	}
+	s.SetBackoff(c.Backoff)
+	s.SetSnapshot(c.Snapshot)
+	s.mutex.Lock()
+	previous := s.config
+	s.mutex.Unlock()
+	if c == previous && s.Running() {
+		return nil
+	}
+	if len(c.URI) == 0 {
+		s.Stop()
+		return nil
+	}
+	if c.FilesURI != previous.FilesURI || c.CleanVP != previous.CleanVP || c.Connector != previous.Connector ||
+		c.TimeZoneLocation != previous.TimeZoneLocation {
+		return fmt.Errorf("files-uri, clean-vp, connector and timezone-location can not be reloaded, " +
+			"forseti must be restarted")
+	}
+	uri, err := url.Parse(c.URI)
+	if err != nil {
+		return err
+	}
+
+	s.Stop()
+	s.context.UpdateConnector(*uri, c.Token, c.Refresh, c.ConnectionTimeout)
+	s.mutex.Lock()
+	s.config = c
+	s.mutex.Unlock()
+	s.SetRefresh(c.Refresh)
+	s.ManageRefreshStatus(c.RefreshActive)
+	s.Start()
+	return nil
+}
+
+func (s *VehiclePositionsSource) AddEntryPoints(r *gin.Engine) {
+	AddVehiclePositionsEntryPoint(r, s.context)
+}
+
+func (s *VehiclePositionsSource) GetStatus() sources.Status {
+	status := s.Refresher.Status()
+	status.LastUpdate = s.context.GetLastVehiclePositionsDataUpdate()
+	return status
+}
+
+func (s *VehiclePositionsSource) LoadedItems() int {
+	return s.context.GetVehiclePositionsCount()
+}
+
+func (s *VehiclePositionsSource) URI() url.URL {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	uri, err := url.Parse(s.config.URI)
+	if err != nil {
+		return url.URL{}
+	}
+	return *uri
+}
+
+func (s *VehiclePositionsSource) WriteSnapshot(w io.Writer) error {
+	return s.context.GetAllVehiclePositions().WriteSnapshot(w)
+}
+
+func (s *VehiclePositionsSource) ReadSnapshot(r io.Reader) error {
+	return s.context.GetAllVehiclePositions().ReadSnapshot(r)
+}
+
+// LoadPayload loads the vehicle positions pushed by the provider, if the connector accepts pushed data
+func (s *VehiclePositionsSource) LoadPayload(r io.Reader) error {
+	payloadLoader, ok := s.context.(sources.PayloadLoader)
+	if !ok {
+		return sources.ErrNoIngest
+	}
+	return payloadLoader.LoadPayload(r)
+}
@@ -1,7 +1,9 @@
 package vehiclepositions
 
 import (
+	"encoding/gob"
 	"fmt"
+	"io"
 	"sync"
 	"time"
 
@@ -16,17 +18,9 @@ import (
 type VehiclePositions struct {
 	vehiclePositions           map[int]*VehiclePosition
 	lastVehiclePositionsUpdate time.Time
-	loadOccupancyData          bool
 	mutex                      sync.RWMutex
 }
 
-func (d *VehiclePositions) ManageVehiclePositionsStatus(activate bool) {
-	d.mutex.Lock()
-	defer d.mutex.Unlock()
-
-	d.loadOccupancyData = activate
-}
-
 func (d *VehiclePositions) CleanListVehiclePositions(timeCleanVP time.Duration) {
 	d.mutex.Lock()
 	defer d.mutex.Unlock()
@@ -110,10 +104,11 @@ func (d *VehiclePositions) GetLastVehiclePositionsDataUpdate() time.Time {
 	return d.lastVehiclePositionsUpdate
 }
 
-func (d *VehiclePositions) LoadPositionsData() bool {
+func (d *VehiclePositions) GetVehiclePositionsCount() int {
 	d.mutex.RLock()
 	defer d.mutex.RUnlock()
-	return d.loadOccupancyData
+
+	return len(d.vehiclePositions)
 }
 
 func NewVehiclePosition(id int, sourceCode string, date time.Time, lat float32, lon float32, bearing float32,
@@ -142,3 +137,37 @@ func FoundIt(vp VehiclePosition, vjCodes []string) bool {
 	}
 	return false
 }
+
+// vehiclePositionsSnapshot is the content of the snapshot of the vehicle positions
+type vehiclePositionsSnapshot struct {
+	VehiclePositions map[int]*VehiclePosition
+	LastUpdate       time.Time
+}
+
+// WriteSnapshot writes the vehicle positions with the time of their last update
+func (d *VehiclePositions) WriteSnapshot(w io.Writer) error {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	if d.vehiclePositions == nil {
+		return fmt.Errorf("no vehicle positions to save")
+	}
+	return gob.NewEncoder(w).Encode(vehiclePositionsSnapshot{
+		VehiclePositions: d.vehiclePositions,
+		LastUpdate:       d.lastVehiclePositionsUpdate,
+	})
+}
+
+// ReadSnapshot replaces the vehicle positions by the ones of a snapshot, with their time of update
+func (d *VehiclePositions) ReadSnapshot(r io.Reader) error {
+	var snapshot vehiclePositionsSnapshot
+	if err := gob.NewDecoder(r).Decode(&snapshot); err != nil {
+		return err
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.vehiclePositions = snapshot.VehiclePositions
+	d.lastVehiclePositionsUpdate = snapshot.LastUpdate
+	return nil
+}